@@ -10,12 +10,12 @@ func BenchmarkQuery(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -50,12 +50,12 @@ func BenchmarkQueryMultipleConditions(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -90,12 +90,12 @@ func BenchmarkQuerySorting(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -129,12 +129,12 @@ func BenchmarkQueryLimitOffset(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -170,12 +170,12 @@ func BenchmarkQueryCount(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -209,12 +209,12 @@ func BenchmarkQueryCountIndex(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -246,12 +246,12 @@ func BenchmarkQueryNoConditions(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -282,12 +282,12 @@ func BenchmarkQueryNonIndexedField(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -321,12 +321,12 @@ func BenchmarkQueryComplexOperators(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -361,12 +361,12 @@ func BenchmarkQueryLargeLimit(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -400,12 +400,12 @@ func BenchmarkQueryOffsetOnly(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -439,12 +439,12 @@ func BenchmarkQuerySortingAscending(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -477,12 +477,12 @@ func BenchmarkQueryCountNoConditions(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -511,12 +511,12 @@ func BenchmarkQueryCountNonIndexed(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -550,12 +550,12 @@ func BenchmarkDeleteQuery(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		os.Remove("benchmark.db")
 		os.Remove("benchmark.db.wal")
-		err := copyFile("benchmark_template.db", "benchmark.db")
+		err := copyFile(benchmarkTemplate(b), "benchmark.db")
 		if err != nil {
 			b.Fatalf("Failed to copy template DB: %v", err)
 		}
-		if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-			copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+		if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+			copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 		}
 
 		db, err := Open("benchmark.db")
@@ -6,6 +6,8 @@ import (
 	"io"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -30,19 +32,47 @@ func copyFile(src, dst string) error {
 
 const userCount = 10000
 
-// TestSetupBenchmarkDB creates a template database for benchmarks
-func TestSetupBenchmarkDB(t *testing.T) {
-	os.Remove("benchmark_template.db")
-	os.Remove("benchmark_template.db.wal")
-	db, err := Open("benchmark_template.db")
+var (
+	benchmarkTemplateDir  string // Temporary directory holding the template database, removed after the run
+	benchmarkTemplateOnce sync.Once
+	benchmarkTemplateErr  error
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "nnut-benchmark")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create template directory: %v\n", err)
+		os.Exit(1)
+	}
+	benchmarkTemplateDir = dir
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// benchmarkTemplate returns the path of the template database for benchmarks, creating it on first use
+func benchmarkTemplate(b *testing.B) string {
+	path := filepath.Join(benchmarkTemplateDir, "benchmark_template.db")
+	benchmarkTemplateOnce.Do(func() {
+		benchmarkTemplateErr = setupBenchmarkDB(path)
+	})
+	if benchmarkTemplateErr != nil {
+		b.Fatalf("Failed to create template DB: %v", benchmarkTemplateErr)
+	}
+	return path
+}
+
+// setupBenchmarkDB creates a template database for benchmarks
+func setupBenchmarkDB(path string) error {
+	db, err := Open(path)
 	if err != nil {
-		t.Fatalf("Failed to open DB: %v", err)
+		return err
 	}
 	defer db.Close()
 
 	store, err := NewStore[TestUser](db, "users")
 	if err != nil {
-		t.Fatalf("Failed to create store: %v", err)
+		return err
 	}
 
 	// Create diverse test data for realistic benchmarking
@@ -55,24 +85,25 @@ func TestSetupBenchmarkDB(t *testing.T) {
 		testUser := TestUser{UUID: key, Name: name, Email: email, Age: age}
 		err := store.Put(context.Background(), testUser)
 		if err != nil {
-			t.Fatalf("Failed to put: %v", err)
+			return err
 		}
 	}
 	db.Flush()
-	os.Remove("benchmark_template.db.wal") // Remove WAL after flush to avoid replay issues
+	os.Remove(path + ".wal") // Remove WAL after flush to avoid replay issues
 	// Leave the DB file behind for benchmarks to copy
+	return nil
 }
 
 func BenchmarkCount(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
-	if _, err := os.Stat("benchmark_template.db.wal"); err == nil {
-		copyFile("benchmark_template.db.wal", "benchmark.db.wal")
+	if _, err := os.Stat(benchmarkTemplate(b) + ".wal"); err == nil {
+		copyFile(benchmarkTemplate(b)+".wal", "benchmark.db.wal")
 	}
 
 	db, err := Open("benchmark.db")
@@ -101,7 +132,7 @@ func BenchmarkGet(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
@@ -133,7 +164,7 @@ func BenchmarkBatchGet(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
@@ -232,7 +263,7 @@ func BenchmarkDelete(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
@@ -265,7 +296,7 @@ func BenchmarkBatchDelete(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
@@ -304,7 +335,7 @@ func BenchmarkHighLoadConcurrent(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
@@ -353,7 +384,7 @@ func BenchmarkWALTruncation(b *testing.B) {
 	// Copy template database
 	os.Remove("benchmark.db")
 	os.Remove("benchmark.db.wal")
-	err := copyFile("benchmark_template.db", "benchmark.db")
+	err := copyFile(benchmarkTemplate(b), "benchmark.db")
 	if err != nil {
 		b.Fatalf("Failed to copy template DB: %v", err)
 	}
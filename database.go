@@ -3,6 +3,7 @@ package nnut
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"hash/crc32"
 	"io"
 	"log"
@@ -33,6 +34,11 @@ type DB struct {
 	operationsBufferMutex sync.Mutex
 	bytesInBuffer         uint64
 	currentEpoch          uint64
+	operationSequence     uint64
+	flushMutex            sync.Mutex
+	sequences             map[string]uint64 // last reserved or written key sequence by bucket name
+	sequencesLoaded       map[string]bool   // buckets whose stored sequence has been merged into sequences
+	sequencesMutex        sync.Mutex
 
 	flushChannel   chan struct{}
 	closeChannel   chan struct{}
@@ -52,6 +58,10 @@ type operation struct {
 	IsPut           bool
 	IndexOperations []indexOperation
 	Epoch           uint64
+	DeletedAt       int64  `msgpack:",omitempty"` // Unix nanoseconds of a soft delete, zero for live records
+	KeySequence     uint64 `msgpack:",omitempty"` // bucket sequence used by an auto-generated key, zero when unused
+
+	sequence uint64 // position in the buffer, used to detect replacement during a flush
 }
 
 type walEntry struct {
@@ -123,6 +133,8 @@ func OpenWithConfig(path string, config *Config) (*DB, error) {
 		DB:               database,
 		config:           config,
 		operationsBuffer: make(map[string]operation),
+		sequences:        make(map[string]uint64),
+		sequencesLoaded:  make(map[string]bool),
 		currentEpoch:     1,
 		flushChannel:     make(chan struct{}, config.FlushChannelSize),
 		closeChannel:     make(chan struct{}),
@@ -147,27 +159,6 @@ func OpenWithConfig(path string, config *Config) (*DB, error) {
 	return databaseInstance, nil
 }
 
-// getLatestBufferedOperation checks the buffer for pending changes to a key
-func (db *DB) getLatestBufferedOperation(bucket []byte, key string) (operation, bool) {
-	db.operationsBufferMutex.Lock()
-	defer db.operationsBufferMutex.Unlock()
-	op, exists := db.operationsBuffer[bufferKey(bucket, key)]
-	return op, exists
-}
-
-// getBufferedOperationsForBucket returns all buffered operations for a specific bucket
-func (db *DB) getBufferedOperationsForBucket(bucket []byte) []operation {
-	db.operationsBufferMutex.Lock()
-	defer db.operationsBufferMutex.Unlock()
-	var ops []operation
-	for _, op := range db.operationsBuffer {
-		if bytes.Equal(op.Bucket, bucket) {
-			ops = append(ops, op)
-		}
-	}
-	return ops
-}
-
 func (db *DB) replayWAL() error {
 	file, err := os.Open(db.config.WALPath)
 	if err != nil {
@@ -217,44 +208,10 @@ func (db *DB) replayWAL() error {
 
 		// Reapply operations to restore database state
 		err = db.Update(func(tx *bbolt.Tx) error {
-			b, err := tx.CreateBucketIfNotExists(operation.Bucket)
+			err := applyOperation(tx, operation)
 			if err != nil {
 				return WALReplayError{WALPath: db.config.WALPath, OperationIndex: operationIndex, Err: err}
 			}
-			if operation.IsPut {
-				err = b.Put([]byte(operation.Key), operation.Value)
-				if err != nil {
-					return WALReplayError{WALPath: db.config.WALPath, OperationIndex: operationIndex, Err: err}
-				}
-			} else {
-				err = b.Delete([]byte(operation.Key))
-				if err != nil {
-					return WALReplayError{WALPath: db.config.WALPath, OperationIndex: operationIndex, Err: err}
-				}
-			}
-
-			// Maintain index consistency during replay
-			for _, idxOp := range operation.IndexOperations {
-				idxBucketName := string(operation.Bucket) + "_index_" + idxOp.IndexName
-				idxB, err := tx.CreateBucketIfNotExists([]byte(idxBucketName))
-				if err != nil {
-					return WALReplayError{WALPath: db.config.WALPath, OperationIndex: operationIndex, Err: IndexError{IndexName: idxOp.IndexName, Operation: "create_bucket", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}}
-				}
-				if idxOp.OldValue != "" {
-					oldKey := idxOp.OldValue + "\x00" + operation.Key
-					err = idxB.Delete([]byte(oldKey))
-					if err != nil {
-						return WALReplayError{WALPath: db.config.WALPath, OperationIndex: operationIndex, Err: IndexError{IndexName: idxOp.IndexName, Operation: "delete", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}}
-					}
-				}
-				if idxOp.NewValue != "" {
-					newKey := idxOp.NewValue + "\x00" + operation.Key
-					err = idxB.Put([]byte(newKey), []byte{})
-					if err != nil {
-						return WALReplayError{WALPath: db.config.WALPath, OperationIndex: operationIndex, Err: IndexError{IndexName: idxOp.IndexName, Operation: "put", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}}
-					}
-				}
-			}
 			return nil
 		})
 		if err != nil {
@@ -287,13 +244,17 @@ func (db *DB) flushWAL() {
 }
 
 func (db *DB) Flush() {
+	db.flushMutex.Lock()
+	defer db.flushMutex.Unlock()
+
+	// Operations stay buffered until committed so readers never miss them
 	db.operationsBufferMutex.Lock()
 	operations := make([]operation, 0, len(db.operationsBuffer))
 	for _, op := range db.operationsBuffer {
-		op.Epoch = db.currentEpoch
 		operations = append(operations, op)
 	}
-	db.operationsBuffer = make(map[string]operation)
+	flushEpoch := db.currentEpoch
+	db.currentEpoch++
 	db.bytesInBuffer = 0
 	db.operationsBufferMutex.Unlock()
 
@@ -303,42 +264,9 @@ func (db *DB) Flush() {
 
 	err := db.Update(func(tx *bbolt.Tx) error {
 		for _, operation := range operations {
-			b, err := tx.CreateBucketIfNotExists(operation.Bucket)
-			if err != nil {
+			if err := applyOperation(tx, operation); err != nil {
 				return err
 			}
-			if operation.IsPut {
-				err = b.Put([]byte(operation.Key), operation.Value)
-				if err != nil {
-					return err
-				}
-			} else {
-				err = b.Delete([]byte(operation.Key))
-				if err != nil {
-					return err
-				}
-			}
-			for _, idxOp := range operation.IndexOperations {
-				idxBucketName := string(operation.Bucket) + "_index_" + idxOp.IndexName
-				idxB, err := tx.CreateBucketIfNotExists([]byte(idxBucketName))
-				if err != nil {
-					return IndexError{IndexName: idxOp.IndexName, Operation: "create_bucket", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}
-				}
-				if idxOp.OldValue != "" {
-					oldKey := idxOp.OldValue + "\x00" + operation.Key
-					err = idxB.Delete([]byte(oldKey))
-					if err != nil {
-						return IndexError{IndexName: idxOp.IndexName, Operation: "delete", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}
-					}
-				}
-				if idxOp.NewValue != "" {
-					newKey := idxOp.NewValue + "\x00" + operation.Key
-					err = idxB.Put([]byte(newKey), []byte{})
-					if err != nil {
-						return IndexError{IndexName: idxOp.IndexName, Operation: "put", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}
-					}
-				}
-			}
 		}
 		return nil
 	})
@@ -348,9 +276,85 @@ func (db *DB) Flush() {
 		return
 	}
 
+	// Drop committed operations unless they were replaced during the flush
+	db.operationsBufferMutex.Lock()
+	for _, op := range operations {
+		key := bufferKey(op.Bucket, op.Key)
+		if current, exists := db.operationsBuffer[key]; exists && current.sequence == op.sequence {
+			delete(db.operationsBuffer, key)
+		}
+	}
+	db.operationsBufferMutex.Unlock()
+
 	// Truncate WAL after successful flush
-	db.truncateWAL(db.currentEpoch)
-	db.currentEpoch++
+	db.truncateWAL(flushEpoch)
+}
+
+// applyOperation writes a single operation and its index changes to the database
+func applyOperation(tx *bbolt.Tx, operation operation) error {
+	b, err := tx.CreateBucketIfNotExists(operation.Bucket)
+	if err != nil {
+		return err
+	}
+	if operation.IsPut {
+		err = b.Put([]byte(operation.Key), operation.Value)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = b.Delete([]byte(operation.Key))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Persist the highest sequence handed out for auto-generated keys
+	if operation.KeySequence > b.Sequence() {
+		err = b.SetSequence(operation.KeySequence)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keep soft-delete markers in step with the record
+	if operation.IsPut && operation.DeletedAt != 0 {
+		tombstones, err := tx.CreateBucketIfNotExists(tombstoneBucketName(operation.Bucket))
+		if err != nil {
+			return err
+		}
+		err = tombstones.Put([]byte(operation.Key), encodeTombstone(operation.DeletedAt))
+		if err != nil {
+			return err
+		}
+	} else if tombstones := tx.Bucket(tombstoneBucketName(operation.Bucket)); tombstones != nil {
+		err = tombstones.Delete([]byte(operation.Key))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, idxOp := range operation.IndexOperations {
+		idxBucketName := string(operation.Bucket) + "_index_" + idxOp.IndexName
+		idxB, err := tx.CreateBucketIfNotExists([]byte(idxBucketName))
+		if err != nil {
+			return IndexError{IndexName: idxOp.IndexName, Operation: "create_bucket", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}
+		}
+		if idxOp.OldValue != "" {
+			oldKey := idxOp.OldValue + "\x00" + operation.Key
+			err = idxB.Delete([]byte(oldKey))
+			if err != nil {
+				return IndexError{IndexName: idxOp.IndexName, Operation: "delete", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}
+			}
+		}
+		if idxOp.NewValue != "" {
+			newKey := idxOp.NewValue + "\x00" + operation.Key
+			err = idxB.Put([]byte(newKey), []byte{})
+			if err != nil {
+				return IndexError{IndexName: idxOp.IndexName, Operation: "put", Bucket: string(operation.Bucket), Key: operation.Key, Err: err}
+			}
+		}
+	}
+	return nil
 }
 
 func (db *DB) truncateWAL(committedEpoch uint64) {
@@ -412,9 +416,9 @@ func (db *DB) truncateWAL(committedEpoch uint64) {
 
 	// Encode remaining operations
 	var buf bytes.Buffer
-	encoder := msgpack.NewEncoder(&buf)
 	for _, op := range remainingOps {
-		if err := encoder.Encode(op); err != nil {
+		encodedEntry, err := encodeWALEntry(op)
+		if err != nil {
 			log.Printf("Error encoding remaining operation: %v", err)
 			// On error, don't truncate
 			db.walFile, err = os.OpenFile(db.config.WALPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
@@ -423,6 +427,7 @@ func (db *DB) truncateWAL(committedEpoch uint64) {
 			}
 			return
 		}
+		buf.Write(encodedEntry)
 	}
 
 	// Write back to WAL
@@ -444,137 +449,197 @@ func (db *DB) truncateWAL(committedEpoch uint64) {
 	}
 }
 
+// tombstoneBucketName returns the name of the bucket holding soft-delete markers
+func tombstoneBucketName(bucket []byte) []byte {
+	return []byte(string(bucket) + "_tombstones")
+}
+
+// encodeTombstone encodes a deletion timestamp for the tombstone bucket
+func encodeTombstone(deletedAt int64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(deletedAt))
+	return data
+}
+
+// decodeTombstone decodes a deletion timestamp from the tombstone bucket
+func decodeTombstone(data []byte) int64 {
+	if len(data) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(data))
+}
+
 // bufferKey generates a unique key for the operations buffer
 func bufferKey(bucket []byte, key string) string {
 	return string(bucket) + "\x00" + key
 }
 
-// writeOperation adds a single operation to WAL and buffer
-func (db *DB) writeOperation(ctx context.Context, op operation) error {
-	op.Epoch = db.currentEpoch
+// bufferOperation stores an operation in the buffer, replacing any pending operation for the same key.
+// The caller must hold operationsBufferMutex.
+func (db *DB) bufferOperation(key string, op operation) {
+	db.operationSequence++
+	op.sequence = db.operationSequence
+	if previous, exists := db.operationsBuffer[key]; exists {
+		// Index changes are relative to the previous state, so keep the ones not yet flushed
+		op.IndexOperations = mergeIndexOperations(previous.IndexOperations, op.IndexOperations)
+		if previous.KeySequence > op.KeySequence {
+			op.KeySequence = previous.KeySequence
+		}
+	}
+	db.operationsBuffer[key] = op
+}
+
+// mergeIndexOperations combines two consecutive sets of index changes into one set relative to the flushed state
+func mergeIndexOperations(previous, next []indexOperation) []indexOperation {
+	if len(previous) == 0 {
+		return next
+	}
+	type indexEntry struct {
+		indexName string
+		value     string
+	}
+	var removed, added []indexEntry
+	removedSet := make(map[indexEntry]bool)
+	addedSet := make(map[indexEntry]bool)
+	nextRemoved := make(map[indexEntry]bool)
+	for _, idxOp := range next {
+		if idxOp.OldValue != "" {
+			nextRemoved[indexEntry{idxOp.IndexName, idxOp.OldValue}] = true
+		}
+	}
+
+	// Entries removed by either operation stay removed
+	for _, operations := range [][]indexOperation{previous, next} {
+		for _, idxOp := range operations {
+			entry := indexEntry{idxOp.IndexName, idxOp.OldValue}
+			if idxOp.OldValue != "" && !removedSet[entry] {
+				removedSet[entry] = true
+				removed = append(removed, entry)
+			}
+		}
+	}
+	// Entries added earlier survive unless removed again
+	for _, idxOp := range previous {
+		entry := indexEntry{idxOp.IndexName, idxOp.NewValue}
+		if idxOp.NewValue != "" && !nextRemoved[entry] && !addedSet[entry] {
+			addedSet[entry] = true
+			added = append(added, entry)
+		}
+	}
+	for _, idxOp := range next {
+		entry := indexEntry{idxOp.IndexName, idxOp.NewValue}
+		if idxOp.NewValue != "" && !addedSet[entry] {
+			addedSet[entry] = true
+			added = append(added, entry)
+		}
+	}
+
+	// Removals are listed first so re-added entries end up present
+	merged := make([]indexOperation, 0, len(removed)+len(added))
+	for _, entry := range removed {
+		merged = append(merged, indexOperation{IndexName: entry.indexName, OldValue: entry.value})
+	}
+	for _, entry := range added {
+		merged = append(merged, indexOperation{IndexName: entry.indexName, NewValue: entry.value})
+	}
+	return merged
+}
 
+// encodeWALEntry encodes an operation together with its checksum as a WAL entry
+func encodeWALEntry(op operation) ([]byte, error) {
 	// Encode operation
 	var opBuf bytes.Buffer
 	opEncoder := msgpack.NewEncoder(&opBuf)
 	err := opEncoder.Encode(op)
 	if err != nil {
-		return WrappedError{Operation: "encode operation", Err: err}
+		return nil, err
 	}
 	encodedOp := opBuf.Bytes()
 
 	// Compute checksum
 	checksum := crc32.ChecksumIEEE(encodedOp)
 
-	// Create WAL entry
+	// Create and encode WAL entry
 	entry := walEntry{Operation: op, Checksum: checksum}
-
-	// Encode entry
 	var entryBuf bytes.Buffer
 	entryEncoder := msgpack.NewEncoder(&entryBuf)
 	err = entryEncoder.Encode(entry)
 	if err != nil {
-		return WrappedError{Operation: "encode WAL entry", Err: err}
-	}
-	encodedEntry := entryBuf.Bytes()
-
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
-	}
-
-	// Write to WAL file
-	db.walMutex.Lock()
-	_, err = db.walFile.Write(encodedEntry)
-	db.walMutex.Unlock()
-	if err != nil {
-		return FileSystemError{Path: db.config.WALPath, Operation: "write", Err: err}
+		return nil, err
 	}
+	return entryBuf.Bytes(), nil
+}
 
-	// Add to buffer with deduplication
-	db.operationsBufferMutex.Lock()
-	key := bufferKey(op.Bucket, op.Key)
-	db.operationsBuffer[key] = op
-	db.bytesInBuffer += uint64(len(encodedEntry))
-	shouldFlush := db.bytesInBuffer >= uint64(db.config.MaxBufferBytes)
-	db.operationsBufferMutex.Unlock()
-
-	if shouldFlush {
-		select {
-		case db.flushChannel <- struct{}{}:
-		default:
-		}
-	}
-	return nil
+// writeOperation adds a single operation to WAL and buffer
+func (db *DB) writeOperation(ctx context.Context, op operation) error {
+	return db.writeOperations(ctx, []operation{op})
 }
 
 // writeOperations adds multiple operations to WAL and buffer atomically
 func (db *DB) writeOperations(ctx context.Context, ops []operation) error {
+	return db.writeCheckedOperations(ctx, ops, nil)
+}
+
+// writeCheckedOperations adds multiple operations to WAL and buffer atomically once check accepts them.
+// The check sees the stored and pending writes of the bucket of the first operation, and no other write
+// can happen between the check and the write.
+func (db *DB) writeCheckedOperations(ctx context.Context, ops []operation, check func(*Snapshot) error) error {
 	if len(ops) == 0 {
 		return nil
 	}
 
-	for i := range ops {
-		ops[i].Epoch = db.currentEpoch
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
 	}
 
-	// Encode all entries
-	var walBuffer bytes.Buffer
-	walEncoder := msgpack.NewEncoder(&walBuffer)
-	totalBytes := uint64(0)
-	for _, op := range ops {
-		// Encode operation
-		var opBuf bytes.Buffer
-		opEncoder := msgpack.NewEncoder(&opBuf)
-		err := opEncoder.Encode(op)
+	// Hold the buffer lock so a flush sees either the whole batch or none of it
+	db.operationsBufferMutex.Lock()
+	defer db.operationsBufferMutex.Unlock()
+
+	if check != nil {
+		snapshot, err := db.snapshotLocked(ops[0].Bucket, nil)
 		if err != nil {
-			return WrappedError{Operation: "encode operation batch", Err: err}
+			return err
 		}
-		encodedOp := opBuf.Bytes()
-
-		// Compute checksum
-		checksum := crc32.ChecksumIEEE(encodedOp)
-
-		// Create WAL entry
-		entry := walEntry{Operation: op, Checksum: checksum}
-
-		// Encode entry
-		err = walEncoder.Encode(entry)
+		err = check(snapshot)
+		snapshot.Release()
 		if err != nil {
-			return WrappedError{Operation: "encode WAL entry batch", Err: err}
+			return err
 		}
-
-		// Measure size
-		var tempBuf bytes.Buffer
-		tempEncoder := msgpack.NewEncoder(&tempBuf)
-		tempEncoder.Encode(entry)
-		totalBytes += uint64(tempBuf.Len())
 	}
-	walBytes := walBuffer.Bytes()
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
+	// Encode all entries
+	var walBuffer bytes.Buffer
+	for i := range ops {
+		ops[i].Epoch = db.currentEpoch
+		encodedEntry, err := encodeWALEntry(ops[i])
+		if err != nil {
+			return WrappedError{Operation: "encode WAL entry", Bucket: string(ops[i].Bucket), Key: ops[i].Key, Err: err}
+		}
+		walBuffer.Write(encodedEntry)
 	}
+	walBytes := walBuffer.Bytes()
 
 	// Write batch to WAL file
 	db.walMutex.Lock()
 	_, err := db.walFile.Write(walBytes)
 	db.walMutex.Unlock()
 	if err != nil {
-		return FileSystemError{Path: db.config.WALPath, Operation: "write_batch", Err: err}
+		return FileSystemError{Path: db.config.WALPath, Operation: "write", Err: err}
 	}
 
 	// Add to buffer with deduplication
-	db.operationsBufferMutex.Lock()
 	for _, op := range ops {
 		key := bufferKey(op.Bucket, op.Key)
-		db.operationsBuffer[key] = op
+		db.bufferOperation(key, op)
+		if op.KeySequence != 0 {
+			db.advanceSequence(op.Bucket, op.KeySequence)
+		}
 	}
-	db.bytesInBuffer += totalBytes
+	db.bytesInBuffer += uint64(len(walBytes))
 	shouldFlush := db.bytesInBuffer >= uint64(db.config.MaxBufferBytes)
-	db.operationsBufferMutex.Unlock()
 
 	if shouldFlush {
 		select {
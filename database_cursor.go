@@ -0,0 +1,197 @@
+package nnut
+
+import (
+	"bytes"
+	"sort"
+
+	"go.etcd.io/bbolt"
+)
+
+// overlayEntry is a pending change to a single key of a bucket
+type overlayEntry struct {
+	key     []byte
+	value   []byte
+	deleted bool
+}
+
+// overlayCursor walks a bucket with pending changes merged on top of the stored entries.
+// It mirrors the bbolt cursor API, returning a nil key once the cursor runs out of entries.
+type overlayCursor struct {
+	cursor  *bbolt.Cursor  // nil when the bucket does not exist yet
+	entries []overlayEntry // sorted by key
+
+	baseKey   []byte // current position of the stored cursor
+	baseValue []byte
+	position  int // current position in entries
+	forward   bool
+	key       []byte // last returned key
+}
+
+// newOverlayCursor creates a cursor over the bucket and pending entries
+func newOverlayCursor(bucket *bbolt.Bucket, entries []overlayEntry) *overlayCursor {
+	cursor := &overlayCursor{entries: entries}
+	if bucket != nil {
+		cursor.cursor = bucket.Cursor()
+	}
+	return cursor
+}
+
+// First moves to the first key and returns it
+func (c *overlayCursor) First() ([]byte, []byte) {
+	c.forward = true
+	c.baseKey, c.baseValue = nil, nil
+	if c.cursor != nil {
+		c.baseKey, c.baseValue = c.cursor.First()
+	}
+	c.position = 0
+	return c.stepForward()
+}
+
+// Last moves to the last key and returns it
+func (c *overlayCursor) Last() ([]byte, []byte) {
+	c.forward = false
+	c.baseKey, c.baseValue = nil, nil
+	if c.cursor != nil {
+		c.baseKey, c.baseValue = c.cursor.Last()
+	}
+	c.position = len(c.entries) - 1
+	return c.stepBackward()
+}
+
+// Seek moves to the first key greater than or equal to seek and returns it
+func (c *overlayCursor) Seek(seek []byte) ([]byte, []byte) {
+	c.forward = true
+	c.baseKey, c.baseValue = nil, nil
+	if c.cursor != nil {
+		c.baseKey, c.baseValue = c.cursor.Seek(seek)
+	}
+	c.position = sort.Search(len(c.entries), func(i int) bool {
+		return bytes.Compare(c.entries[i].key, seek) >= 0
+	})
+	return c.stepForward()
+}
+
+// Next moves to the next key and returns it
+func (c *overlayCursor) Next() ([]byte, []byte) {
+	if c.key == nil {
+		return nil, nil
+	}
+	if !c.forward {
+		// Reposition both sources just after the current key
+		c.forward = true
+		c.baseKey, c.baseValue = nil, nil
+		if c.cursor != nil {
+			c.baseKey, c.baseValue = c.cursor.Seek(c.key)
+			for c.baseKey != nil && bytes.Compare(c.baseKey, c.key) <= 0 {
+				c.baseKey, c.baseValue = c.cursor.Next()
+			}
+		}
+		c.position = sort.Search(len(c.entries), func(i int) bool {
+			return bytes.Compare(c.entries[i].key, c.key) > 0
+		})
+	}
+	return c.stepForward()
+}
+
+// Prev moves to the previous key and returns it
+func (c *overlayCursor) Prev() ([]byte, []byte) {
+	if c.key == nil {
+		return nil, nil
+	}
+	if c.forward {
+		// Reposition both sources just before the current key
+		c.forward = false
+		c.baseKey, c.baseValue = nil, nil
+		if c.cursor != nil {
+			c.baseKey, c.baseValue = c.cursor.Seek(c.key)
+			if c.baseKey == nil {
+				c.baseKey, c.baseValue = c.cursor.Last()
+			}
+			for c.baseKey != nil && bytes.Compare(c.baseKey, c.key) >= 0 {
+				c.baseKey, c.baseValue = c.cursor.Prev()
+			}
+		}
+		c.position = sort.Search(len(c.entries), func(i int) bool {
+			return bytes.Compare(c.entries[i].key, c.key) >= 0
+		}) - 1
+	}
+	return c.stepBackward()
+}
+
+// stepForward returns the smallest key of both sources and advances past it
+func (c *overlayCursor) stepForward() ([]byte, []byte) {
+	for {
+		var entry *overlayEntry
+		if c.position < len(c.entries) {
+			entry = &c.entries[c.position]
+		}
+		if entry == nil && c.baseKey == nil {
+			c.key = nil
+			return nil, nil
+		}
+
+		comparison := -1
+		if entry != nil && c.baseKey != nil {
+			comparison = bytes.Compare(c.baseKey, entry.key)
+		} else if entry != nil {
+			comparison = 1
+		}
+
+		if comparison < 0 {
+			// Stored entry without pending changes
+			key, value := c.baseKey, c.baseValue
+			c.baseKey, c.baseValue = c.cursor.Next()
+			c.key = key
+			return key, value
+		}
+		if comparison == 0 {
+			// Pending change replaces the stored entry
+			c.baseKey, c.baseValue = c.cursor.Next()
+		}
+		c.position++
+		if entry.deleted {
+			continue
+		}
+		c.key = entry.key
+		return entry.key, entry.value
+	}
+}
+
+// stepBackward returns the largest key of both sources and moves before it
+func (c *overlayCursor) stepBackward() ([]byte, []byte) {
+	for {
+		var entry *overlayEntry
+		if c.position >= 0 && c.position < len(c.entries) {
+			entry = &c.entries[c.position]
+		}
+		if entry == nil && c.baseKey == nil {
+			c.key = nil
+			return nil, nil
+		}
+
+		comparison := 1
+		if entry != nil && c.baseKey != nil {
+			comparison = bytes.Compare(c.baseKey, entry.key)
+		} else if entry != nil {
+			comparison = -1
+		}
+
+		if comparison > 0 {
+			// Stored entry without pending changes
+			key, value := c.baseKey, c.baseValue
+			c.baseKey, c.baseValue = c.cursor.Prev()
+			c.key = key
+			return key, value
+		}
+		if comparison == 0 {
+			// Pending change replaces the stored entry
+			c.baseKey, c.baseValue = c.cursor.Prev()
+		}
+		c.position--
+		if entry.deleted {
+			continue
+		}
+		c.key = entry.key
+		return entry.key, entry.value
+	}
+}
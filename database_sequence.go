@@ -0,0 +1,41 @@
+package nnut
+
+// reserveSequence reserves count consecutive key sequences for a bucket and returns the first one.
+// The stored sequence is loaded with initial on first use; it is persisted with the operations
+// that use the sequences, so reservations that are never written can be handed out again after a restart.
+func (db *DB) reserveSequence(bucket []byte, count uint64, initial func() (uint64, error)) (uint64, error) {
+	db.sequencesMutex.Lock()
+	loaded := db.sequencesLoaded[string(bucket)]
+	db.sequencesMutex.Unlock()
+
+	if !loaded {
+		// Load outside the lock, as reading takes the buffer lock that writes hold while advancing
+		stored, err := initial()
+		if err != nil {
+			return 0, err
+		}
+		db.sequencesMutex.Lock()
+		if stored > db.sequences[string(bucket)] {
+			db.sequences[string(bucket)] = stored
+		}
+		db.sequencesLoaded[string(bucket)] = true
+		db.sequencesMutex.Unlock()
+	}
+
+	db.sequencesMutex.Lock()
+	defer db.sequencesMutex.Unlock()
+	last := db.sequences[string(bucket)]
+	db.sequences[string(bucket)] = last + count
+	return last + 1, nil
+}
+
+// advanceSequence moves the sequence of a bucket forward to a written key sequence, so later
+// reservations do not hand it out again
+func (db *DB) advanceSequence(bucket []byte, sequence uint64) {
+	db.sequencesMutex.Lock()
+	defer db.sequencesMutex.Unlock()
+
+	if sequence > db.sequences[string(bucket)] {
+		db.sequences[string(bucket)] = sequence
+	}
+}
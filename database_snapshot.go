@@ -0,0 +1,210 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"go.etcd.io/bbolt"
+)
+
+// Snapshot is a consistent, read-only view of the database. It combines a read transaction
+// with the writes that were still buffered when it was taken, so reads through a snapshot
+// see every write made before it and none made after it.
+//
+// A snapshot holds a bbolt read transaction and must be released when no longer needed.
+// It is not safe for concurrent use, and the goroutine holding it should release it before writing.
+//
+// Example:
+//
+//	snapshot, err := db.Snapshot(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	defer snapshot.Release()
+//	user, err := userStore.In(snapshot).Get(ctx, "aa0000a0...")
+type Snapshot struct {
+	tx         *bbolt.Tx
+	operations map[string]operation      // pending operations by buffer key
+	overlays   map[string][]overlayEntry // pending entries by bucket name, built on first use
+}
+
+// Snapshot captures the write buffer together with a read transaction
+func (db *DB) Snapshot(ctx context.Context) (*Snapshot, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	return db.snapshot(nil, nil)
+}
+
+// Release ends the read transaction held by the snapshot
+func (snapshot *Snapshot) Release() error {
+	return snapshot.tx.Rollback()
+}
+
+// snapshot captures the pending operations for a bucket, or all buckets when bucket is nil,
+// limited to the given keys when keys is not nil
+func (db *DB) snapshot(bucket []byte, keys []string) (*Snapshot, error) {
+	db.operationsBufferMutex.Lock()
+	defer db.operationsBufferMutex.Unlock()
+	return db.snapshotLocked(bucket, keys)
+}
+
+// snapshotLocked captures a snapshot like snapshot, with the buffer lock already held by the caller
+func (db *DB) snapshotLocked(bucket []byte, keys []string) (*Snapshot, error) {
+	var operations map[string]operation
+	if keys != nil {
+		operations = make(map[string]operation, len(keys))
+		for _, key := range keys {
+			bufferedKey := bufferKey(bucket, key)
+			if op, exists := db.operationsBuffer[bufferedKey]; exists {
+				operations[bufferedKey] = op
+			}
+		}
+	} else {
+		operations = make(map[string]operation)
+		for bufferedKey, op := range db.operationsBuffer {
+			if bucket == nil || bytes.Equal(op.Bucket, bucket) {
+				operations[bufferedKey] = op
+			}
+		}
+	}
+
+	// Operations only leave the buffer once committed, so beginning the transaction
+	// while holding the buffer lock guarantees no write is missed or seen twice
+	tx, err := db.Begin(false)
+	if err != nil {
+		return nil, WrappedError{Operation: "begin snapshot", Bucket: string(bucket), Err: err}
+	}
+	return &Snapshot{tx: tx, operations: operations}, nil
+}
+
+// view runs fn against a snapshot of a bucket, limited to the given keys when keys is not nil
+func (db *DB) view(ctx context.Context, bucket []byte, keys []string, fn func(*Snapshot) error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	snapshot, err := db.snapshot(bucket, keys)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+	return fn(snapshot)
+}
+
+// overlay returns the pending entries of a bucket sorted by key
+func (snapshot *Snapshot) overlay(bucketName []byte) []overlayEntry {
+	if snapshot.overlays == nil {
+		snapshot.overlays = make(map[string][]overlayEntry)
+		for _, op := range snapshot.operations {
+			primaryName := string(op.Bucket)
+			snapshot.overlays[primaryName] = append(snapshot.overlays[primaryName], overlayEntry{
+				key:     []byte(op.Key),
+				value:   op.Value,
+				deleted: !op.IsPut,
+			})
+
+			tombstoneName := string(tombstoneBucketName(op.Bucket))
+			tombstone := overlayEntry{key: []byte(op.Key), deleted: true}
+			if op.IsPut && op.DeletedAt != 0 {
+				tombstone = overlayEntry{key: []byte(op.Key), value: encodeTombstone(op.DeletedAt)}
+			}
+			snapshot.overlays[tombstoneName] = append(snapshot.overlays[tombstoneName], tombstone)
+
+			// Removals are applied before additions, so an entry that is both removed and added is kept
+			type indexEntry struct {
+				bucketName string
+				key        string
+			}
+			indexEntries := make(map[indexEntry]bool)
+			for _, idxOp := range op.IndexOperations {
+				if idxOp.OldValue != "" {
+					indexEntries[indexEntry{primaryName + "_index_" + idxOp.IndexName, idxOp.OldValue + "\x00" + op.Key}] = false
+				}
+			}
+			for _, idxOp := range op.IndexOperations {
+				if idxOp.NewValue != "" {
+					indexEntries[indexEntry{primaryName + "_index_" + idxOp.IndexName, idxOp.NewValue + "\x00" + op.Key}] = true
+				}
+			}
+			for entry, added := range indexEntries {
+				snapshot.overlays[entry.bucketName] = append(snapshot.overlays[entry.bucketName], overlayEntry{
+					key:     []byte(entry.key),
+					value:   []byte{},
+					deleted: !added,
+				})
+			}
+		}
+		for _, entries := range snapshot.overlays {
+			sort.Slice(entries, func(i, j int) bool {
+				return bytes.Compare(entries[i].key, entries[j].key) < 0
+			})
+		}
+	}
+	return snapshot.overlays[string(bucketName)]
+}
+
+// cursor returns a cursor over a bucket with pending entries merged in
+func (snapshot *Snapshot) cursor(bucketName []byte) *overlayCursor {
+	return newOverlayCursor(snapshot.tx.Bucket(bucketName), snapshot.overlay(bucketName))
+}
+
+// get returns the value of a key in a bucket, or nil when it does not exist
+func (snapshot *Snapshot) get(bucketName []byte, key []byte) []byte {
+	entries := snapshot.overlay(bucketName)
+	position := sort.Search(len(entries), func(i int) bool {
+		return bytes.Compare(entries[i].key, key) >= 0
+	})
+	if position < len(entries) && bytes.Equal(entries[position].key, key) {
+		if entries[position].deleted {
+			return nil
+		}
+		return entries[position].value
+	}
+	bucket := snapshot.tx.Bucket(bucketName)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Get(key)
+}
+
+// exists reports whether a bucket exists or has pending entries
+func (snapshot *Snapshot) exists(bucketName []byte) bool {
+	return snapshot.tx.Bucket(bucketName) != nil || len(snapshot.overlay(bucketName)) > 0
+}
+
+// count returns the number of keys in a bucket including pending entries
+func (snapshot *Snapshot) count(bucketName []byte) int {
+	count := 0
+	bucket := snapshot.tx.Bucket(bucketName)
+	if bucket != nil {
+		count = bucket.Stats().KeyN
+	}
+	for _, entry := range snapshot.overlay(bucketName) {
+		stored := bucket != nil && bucket.Get(entry.key) != nil
+		if entry.deleted && stored {
+			count--
+		} else if !entry.deleted && !stored {
+			count++
+		}
+	}
+	return count
+}
+
+// sequence returns the highest key sequence of a bucket including pending operations
+func (snapshot *Snapshot) sequence(bucketName []byte) uint64 {
+	var sequence uint64
+	if bucket := snapshot.tx.Bucket(bucketName); bucket != nil {
+		sequence = bucket.Sequence()
+	}
+	for _, op := range snapshot.operations {
+		if op.KeySequence > sequence && bytes.Equal(op.Bucket, bucketName) {
+			sequence = op.KeySequence
+		}
+	}
+	return sequence
+}
@@ -0,0 +1,162 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// One flushed and one buffered record
+	err = store.Put(context.Background(), TestUser{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	db.Flush()
+	err = store.Put(context.Background(), TestUser{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	snapshot, err := db.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	defer snapshot.Release()
+
+	// Writes after the snapshot are not visible through it
+	err = store.Put(context.Background(), TestUser{UUID: "3", Name: "Charlie", Email: "charlie@example.com", Age: 35})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	err = store.Delete(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	view := store.In(snapshot)
+	user, err := view.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to get flushed record: %v", err)
+	}
+	if user.Name != "Alice" {
+		t.Fatalf("Expected Alice, got %s", user.Name)
+	}
+	_, err = view.Get(context.Background(), "2")
+	if err != nil {
+		t.Fatalf("Failed to get buffered record: %v", err)
+	}
+	_, err = view.Get(context.Background(), "3")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError, got %v", err)
+	}
+
+	count, err := view.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected count 2, got %d", count)
+	}
+
+	results, err := view.GetQuery(context.Background(), &Query{
+		Conditions: []Condition{{Field: "Age", Value: 20, Operator: GreaterThan}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	// The store itself sees the latest state
+	count, err = store.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected count 2, got %d", count)
+	}
+	_, err = store.Get(context.Background(), "1")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError, got %v", err)
+	}
+}
+
+func TestSnapshotBufferedDelete(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUsers := []TestUser{
+		{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30},
+		{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25},
+	}
+	err = store.PutBatch(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+
+	err = store.Delete(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	// Get, GetBatch, Count and GetQuery agree on the buffered delete
+	_, err = store.Get(context.Background(), "1")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError, got %v", err)
+	}
+	results, err := store.GetBatch(context.Background(), []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("Failed to get batch: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if _, exists := results["1"]; exists {
+		t.Fatalf("Expected deleted record to be missing from batch")
+	}
+	count, err := store.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected count 1, got %d", count)
+	}
+	queryResults, err := store.GetQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(queryResults) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(queryResults))
+	}
+}
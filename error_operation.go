@@ -31,6 +31,27 @@ func (e FlushError) Unwrap() error {
 	return e.Err
 }
 
+// UniqueConstraintError indicates that a write gives a unique index a value already held by another record.
+type UniqueConstraintError struct {
+	Bucket string
+	Index  string
+	Key    string // key of the record holding the value
+}
+
+func (e UniqueConstraintError) Error() string {
+	return fmt.Sprintf("unique index '%s' in bucket '%s' already holds the value for key '%s'", e.Index, e.Bucket, e.Key)
+}
+
+// ConflictError indicates that a record kept changing while a write that depends on it was prepared.
+type ConflictError struct {
+	Bucket string
+	Key    string
+}
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("record with key '%s' in bucket '%s' changed concurrently", e.Key, e.Bucket)
+}
+
 // PartialBatchError contains results and errors for batch operations.
 type PartialBatchError struct {
 	SuccessfulCount int              // number of successful operations
@@ -47,6 +47,14 @@ func TestFlushError(t *testing.T) {
 	}
 }
 
+func TestConflictError(t *testing.T) {
+	err := ConflictError{Bucket: "users", Key: "user1"}
+	expected := "record with key 'user1' in bucket 'users' changed concurrently"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
 func TestPartialBatchError(t *testing.T) {
 	failed := map[string]error{
 		"key1": errors.New("decode failed"),
@@ -119,6 +127,38 @@ func TestKeyFieldNotStringError(t *testing.T) {
 	}
 }
 
+func TestKeyFieldTypeError(t *testing.T) {
+	err := KeyFieldTypeError{FieldName: "ID", Type: "float64"}
+	expected := "key field 'ID' has unsupported type 'float64'"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestKeyFieldOrderError(t *testing.T) {
+	err := KeyFieldOrderError{FieldName: "ID", Reason: "order is used by another key field"}
+	expected := "invalid order of key field 'ID': order is used by another key field"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestAutoKeyError(t *testing.T) {
+	err := AutoKeyError{FieldName: "ID", Strategy: "seq", Reason: "requires an integer key field"}
+	expected := "invalid auto key 'seq' for field 'ID': requires an integer key field"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestUniqueConstraintError(t *testing.T) {
+	err := UniqueConstraintError{Bucket: "users", Index: "email", Key: "42"}
+	expected := "unique index 'email' in bucket 'users' already holds the value for key '42'"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
 func TestInvalidFieldTypeError(t *testing.T) {
 	err := InvalidFieldTypeError{FieldName: "Age", Expected: "int", Actual: "string"}
 	expected := "field 'Age' has invalid type 'string', expected 'int'"
@@ -128,8 +168,24 @@ func TestInvalidFieldTypeError(t *testing.T) {
 }
 
 func TestIndexFieldTypeError(t *testing.T) {
-	err := IndexFieldTypeError{FieldName: "Email", Type: "int"}
-	expected := "index field 'Email' must be string, got 'int'"
+	err := IndexFieldTypeError{FieldName: "Tags", Type: "[]string"}
+	expected := "index field 'Tags' has unsupported type '[]string'"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestIndexFieldOrderError(t *testing.T) {
+	err := IndexFieldOrderError{Index: "status_created", FieldName: "Status", Reason: "composite index fields must be numbered"}
+	expected := "invalid order of field 'Status' in index 'status_created': composite index fields must be numbered"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestIndexOptionError(t *testing.T) {
+	err := IndexOptionError{Index: "email", FieldName: "Email", Option: "collate=nfx", Reason: "unsupported normalisation form"}
+	expected := "invalid option 'collate=nfx' of field 'Email' in index 'email': unsupported normalisation form"
 	if err.Error() != expected {
 		t.Errorf("Expected %q, got %q", expected, err.Error())
 	}
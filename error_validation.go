@@ -36,6 +36,9 @@ func (e KeyFieldNotFoundError) Error() string {
 }
 
 // KeyFieldNotStringError indicates that the key field is not a string.
+//
+// Deprecated: key fields are no longer limited to strings, so this error is no longer returned. Unsupported
+// key types are reported with KeyFieldTypeError.
 type KeyFieldNotStringError struct {
 	FieldName string
 }
@@ -44,6 +47,38 @@ func (e KeyFieldNotStringError) Error() string {
 	return fmt.Sprintf("key field '%s' must be of type string", e.FieldName)
 }
 
+// KeyFieldTypeError indicates that the key field has a type that cannot be used as a key.
+// Supported key types are strings, integers, [16]byte arrays and time.Time.
+type KeyFieldTypeError struct {
+	FieldName string
+	Type      string
+}
+
+func (e KeyFieldTypeError) Error() string {
+	return fmt.Sprintf("key field '%s' has unsupported type '%s'", e.FieldName, e.Type)
+}
+
+// KeyFieldOrderError indicates that the parts of a composite key are not numbered correctly.
+type KeyFieldOrderError struct {
+	FieldName string
+	Reason    string
+}
+
+func (e KeyFieldOrderError) Error() string {
+	return fmt.Sprintf("invalid order of key field '%s': %s", e.FieldName, e.Reason)
+}
+
+// AutoKeyError indicates that a key field cannot be generated with the requested strategy.
+type AutoKeyError struct {
+	FieldName string
+	Strategy  string
+	Reason    string
+}
+
+func (e AutoKeyError) Error() string {
+	return fmt.Sprintf("invalid auto key '%s' for field '%s': %s", e.Strategy, e.FieldName, e.Reason)
+}
+
 // InvalidKeyError indicates that the provided key is invalid (e.g., empty).
 type InvalidKeyError struct {
 	Key string
@@ -64,14 +99,37 @@ func (e InvalidFieldTypeError) Error() string {
 	return fmt.Sprintf("field '%s' has invalid type '%s', expected '%s'", e.FieldName, e.Actual, e.Expected)
 }
 
-// IndexFieldTypeError indicates an index field is not a string.
+// IndexFieldTypeError indicates an index field has a type that cannot be indexed.
 type IndexFieldTypeError struct {
 	FieldName string
 	Type      string
 }
 
 func (e IndexFieldTypeError) Error() string {
-	return fmt.Sprintf("index field '%s' must be string, got '%s'", e.FieldName, e.Type)
+	return fmt.Sprintf("index field '%s' has unsupported type '%s'", e.FieldName, e.Type)
+}
+
+// IndexFieldOrderError indicates that the fields of a composite index are not numbered correctly.
+type IndexFieldOrderError struct {
+	Index     string
+	FieldName string
+	Reason    string
+}
+
+func (e IndexFieldOrderError) Error() string {
+	return fmt.Sprintf("invalid order of field '%s' in index '%s': %s", e.FieldName, e.Index, e.Reason)
+}
+
+// IndexOptionError indicates that an option in the index tag of a field is invalid.
+type IndexOptionError struct {
+	Index     string
+	FieldName string
+	Option    string
+	Reason    string
+}
+
+func (e IndexOptionError) Error() string {
+	return fmt.Sprintf("invalid option '%s' of field '%s' in index '%s': %s", e.Option, e.FieldName, e.Index, e.Reason)
 }
 
 // BucketNameError indicates an invalid bucket name.
@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/vmihailenco/msgpack/v5 v5.4.1
 	go.etcd.io/bbolt v1.3.8
+	golang.org/x/text v0.22.0
 )
 
 require (
@@ -2,6 +2,8 @@ package nnut
 
 import (
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -10,17 +12,41 @@ const (
 	MaxBucketNameLength = 255
 )
 
+// StoreConfig holds store configuration options
+type StoreConfig struct {
+	SoftDelete bool           // Mark deleted records with a tombstone instead of removing them
+	FullText   FullTextConfig // Text analysis of fields tagged with nnut:"fulltext"
+}
+
 // Store represents a typed bucket
 type Store[T any] struct {
-	database    *DB
-	bucket      []byte
-	keyField    int            // index of the field tagged with nnut:"key"
-	indexFields map[string]int // index name -> field index
-	fieldMap    map[string]int // field name -> field index
+	database       *DB
+	bucket         []byte
+	keyParts       []keyPart         // fields tagged with nnut:"key", in key order
+	autoKey        string            // strategy for generating empty keys, empty when keys are always given
+	indexFields    map[string][]int  // index name -> field indexes, in index order
+	indexOfField   map[int]string    // field index -> name of the single field index on the field
+	unique         map[string]bool   // names of indexes whose values may be held by one record only
+	multiValue     map[string]bool   // names of indexes over slice or map fields, holding an entry per element or map key
+	collations     map[int]collation // field index -> collation of the string values of an indexed field
+	fullText       map[string]int    // full-text index name -> field index
+	fullTextConfig FullTextConfig
+	fields         []storeField   // fields of the record type and of the structs nested in it
+	fieldMap       map[string]int // field path -> field index in fields
+	softDelete     bool
 }
 
 // NewStore creates a new store for type T with the given bucket name
 func NewStore[T any](database *DB, bucketName string) (*Store[T], error) {
+	return NewStoreWithConfig[T](database, bucketName, &StoreConfig{})
+}
+
+// NewStoreWithConfig creates a new store for type T with the given bucket name and config
+func NewStoreWithConfig[T any](database *DB, bucketName string, config *StoreConfig) (*Store[T], error) {
+	if config == nil {
+		return nil, InvalidConfigError{Field: "config", Value: nil, Reason: "cannot be nil"}
+	}
+
 	// Validate bucket name
 	if bucketName == "" {
 		return nil, BucketNameError{BucketName: bucketName, Reason: "cannot be empty"}
@@ -40,57 +66,275 @@ func NewStore[T any](database *DB, bucketName string) (*Store[T], error) {
 	if typeOfStruct.Kind() != reflect.Struct {
 		return nil, InvalidTypeError{Type: typeOfStruct.String()}
 	}
-	keyFieldIndex := -1
-	indexFields := make(map[string]int)
-	fieldMap := make(map[string]int)
+	var keyParts []keyPart
+	var autoKey string
+	keyOrders := make(map[int]bool)
+	type indexPart struct {
+		field int
+		order int // position given in the nnut:"index:name:N" tag, zero for a single field index
+	}
+	indexParts := make(map[string][]indexPart)
+	unique := make(map[string]bool)
+	collations := make(map[int]collation)
+	fullText := make(map[string]int)
 	for fieldIndex := 0; fieldIndex < typeOfStruct.NumField(); fieldIndex++ {
 		field := typeOfStruct.Field(fieldIndex)
-		fieldMap[field.Name] = fieldIndex
-		tagValue := field.Tag.Get("nnut")
-		if tagValue == "key" {
-			if field.Type.Kind() != reflect.String {
-				return nil, KeyFieldNotStringError{FieldName: field.Name}
+		for _, tag := range strings.Split(field.Tag.Get("nnut"), ";") {
+			tagValue, tagOptions, _ := strings.Cut(tag, ",")
+			if tagValue == "key" || strings.HasPrefix(tagValue, "key:") {
+				kind, ok := keyKindOf(field.Type)
+				if !ok {
+					return nil, KeyFieldTypeError{FieldName: field.Name, Type: field.Type.String()}
+				}
+				// Composite keys number their parts, as in nnut:"key:1" and nnut:"key:2"
+				order := 0
+				if tagValue != "key" {
+					var err error
+					order, err = strconv.Atoi(strings.TrimPrefix(tagValue, "key:"))
+					if err != nil || order < 1 {
+						return nil, KeyFieldOrderError{FieldName: field.Name, Reason: "order must be a positive number"}
+					}
+				}
+				if keyOrders[order] {
+					return nil, KeyFieldOrderError{FieldName: field.Name, Reason: "order is used by another key field"}
+				}
+				keyOrders[order] = true
+				keyParts = append(keyParts, keyPart{field: fieldIndex, order: order, kind: kind, fieldType: field.Type})
+
+				// Keys can be generated when left empty, as in nnut:"key,auto=seq"
+				if strategy, ok := strings.CutPrefix(tagOptions, "auto="); ok {
+					if order != 0 {
+						return nil, AutoKeyError{FieldName: field.Name, Strategy: strategy, Reason: "not supported for composite keys"}
+					}
+					if err := validateAutoKey(strategy, kind); err != nil {
+						return nil, AutoKeyError{FieldName: field.Name, Strategy: strategy, Reason: err.Error()}
+					}
+					autoKey = strategy
+				}
 			}
-			keyFieldIndex = fieldIndex
-		} else if strings.HasPrefix(tagValue, "index:") {
-			parts := strings.Split(tagValue, ":")
-			if len(parts) == 2 {
-				indexName := parts[1]
-				indexFields[indexName] = fieldIndex
+		}
+	}
+
+	// Indexes can be placed on fields of nested and embedded structs as well
+	fields, fieldMap := collectFields(typeOfStruct)
+	// A field can be part of several indexes, with their tags separated by semicolons, as in
+	// nnut:"index:status;index:status_created:1"
+	for position, field := range fields {
+		for _, tag := range strings.Split(field.tag, ";") {
+			tagValue, tagOptions, _ := strings.Cut(tag, ",")
+			if tagValue == "fulltext" {
+				// Full-text indexes hold the terms of a text field, as in nnut:"fulltext"
+				if field.fieldType.Kind() != reflect.String {
+					return nil, IndexFieldTypeError{FieldName: field.name, Type: field.fieldType.String()}
+				}
+				fullText[fullTextIndexName(field.name)] = position
+			} else if strings.HasPrefix(tagValue, "index:") {
+				// Composite indexes number their fields, as in nnut:"index:status_created:1" and nnut:"index:status_created:2"
+				parts := strings.Split(tagValue, ":")
+				if len(parts) == 2 || len(parts) == 3 {
+					indexName := parts[1]
+					order := 0
+					if len(parts) == 3 {
+						var err error
+						order, err = strconv.Atoi(parts[2])
+						if err != nil || order < 1 {
+							return nil, IndexFieldOrderError{Index: indexName, FieldName: field.name, Reason: "order must be a positive number"}
+						}
+					}
+					indexParts[indexName] = append(indexParts[indexName], indexPart{field: position, order: order})
+					// Unique indexes are marked with an option, as in nnut:"index:email,unique",
+					// and string values are normalised with nnut:"index:email,fold" and nnut:"index:email,collate=nfkc"
+					var fieldCollation collation
+					var collationOption string
+					for _, option := range strings.Split(tagOptions, ",") {
+						if option == "unique" {
+							unique[indexName] = true
+						} else if option == "fold" {
+							fieldCollation.fold = true
+							collationOption = option
+						} else if form, ok := strings.CutPrefix(option, "collate="); ok {
+							if _, ok := collationForms[form]; !ok {
+								return nil, IndexOptionError{Index: indexName, FieldName: field.name, Option: option, Reason: "unsupported normalisation form"}
+							}
+							fieldCollation.form = form
+							collationOption = option
+						}
+					}
+					if fieldCollation != (collation{}) {
+						if valueType, _, _ := indexedType(field.fieldType); valueType.Kind() != reflect.String {
+							return nil, IndexOptionError{Index: indexName, FieldName: field.name, Option: collationOption, Reason: "only applies to string fields"}
+						}
+						// Values are normalised once per field, so every index of the field shares the normalisation
+						if existing, ok := collations[position]; ok && existing != fieldCollation {
+							return nil, IndexOptionError{Index: indexName, FieldName: field.name, Option: collationOption, Reason: "differs from another index of the field"}
+						}
+						collations[position] = fieldCollation
+					}
+				}
 			}
 		}
 	}
-	if keyFieldIndex == -1 {
+	if len(keyParts) == 0 {
 		return nil, KeyFieldNotFoundError{}
 	}
+	if len(keyParts) > 1 && keyOrders[0] {
+		return nil, KeyFieldOrderError{FieldName: typeOfStruct.Field(keyParts[0].field).Name, Reason: "composite key fields must be numbered"}
+	}
+	sort.Slice(keyParts, func(i, j int) bool {
+		return keyParts[i].order < keyParts[j].order
+	})
 
-	// Validate index fields are strings or comparable (int)
-	for indexName, fieldIndex := range indexFields {
-		field := typeOfStruct.Field(fieldIndex)
-		kind := field.Type.Kind()
-		if kind != reflect.String && kind != reflect.Int {
-			return nil, IndexFieldTypeError{FieldName: field.Name, Type: field.Type.String()}
+	// Order the fields of composite indexes and validate index fields have an order-preserving encoding
+	indexFields := make(map[string][]int, len(indexParts))
+	indexOfField := make(map[int]string)
+	multiValue := make(map[string]bool)
+	for indexName, parts := range indexParts {
+		orders := make(map[int]bool)
+		for _, part := range parts {
+			field := fields[part.field]
+			if len(parts) > 1 && part.order == 0 {
+				return nil, IndexFieldOrderError{Index: indexName, FieldName: field.name, Reason: "composite index fields must be numbered"}
+			}
+			if orders[part.order] {
+				return nil, IndexFieldOrderError{Index: indexName, FieldName: field.name, Reason: "order is used by another field of the index"}
+			}
+			orders[part.order] = true
+			_, isMultiValue, ok := indexedType(field.fieldType)
+			if !ok || (isMultiValue && len(parts) > 1) {
+				return nil, IndexFieldTypeError{FieldName: field.name, Type: field.fieldType.String()}
+			}
+			if isMultiValue {
+				multiValue[indexName] = true
+			}
+		}
+		sort.Slice(parts, func(i, j int) bool {
+			return parts[i].order < parts[j].order
+		})
+		for _, part := range parts {
+			indexFields[indexName] = append(indexFields[indexName], part.field)
+		}
+		// Fields with several single field indexes answer through the first by name
+		if existing, ok := indexOfField[parts[0].field]; len(parts) == 1 && (!ok || indexName < existing) {
+			indexOfField[parts[0].field] = indexName
 		}
-		_ = indexName // avoid unused variable
 	}
 
 	return &Store[T]{
-		database:    database,
-		bucket:      []byte(bucketName),
-		keyField:    keyFieldIndex,
-		indexFields: indexFields,
-		fieldMap:    fieldMap,
+		database:       database,
+		bucket:         []byte(bucketName),
+		keyParts:       keyParts,
+		autoKey:        autoKey,
+		indexFields:    indexFields,
+		indexOfField:   indexOfField,
+		unique:         unique,
+		multiValue:     multiValue,
+		collations:     collations,
+		fullText:       fullText,
+		fullTextConfig: config.FullText,
+		fields:         fields,
+		fieldMap:       fieldMap,
+		softDelete:     config.SoftDelete,
 	}, nil
 }
 
+// fieldIndexValues returns the entry values of a record in a single field index, reporting false when the field has
+// no value
+func (s *Store[T]) fieldIndexValues(structValue reflect.Value, indexName string) ([]string, bool) {
+	fieldIndex := s.indexFields[indexName][0]
+	fieldValue, ok := s.fieldValue(structValue, fieldIndex)
+	if !ok {
+		return nil, false
+	}
+	if s.multiValue[indexName] {
+		return s.encodeIndexValues(fieldIndex, fieldValue), true
+	}
+	return []string{s.collate(fieldIndex, encodeIndexValue(fieldValue))}, true
+}
+
 // Gather index field values to maintain secondary index consistency
-func (s *Store[T]) extractIndexValues(value T) map[string]string {
+func (s *Store[T]) extractIndexValues(value T) map[string][]string {
 	structValue := reflect.ValueOf(value)
-	result := make(map[string]string)
-	for indexName, fieldIndex := range s.indexFields {
-		fieldValue := structValue.Field(fieldIndex)
-		if fieldValue.Kind() == reflect.String {
-			result[indexName] = fieldValue.String()
+	result := make(map[string][]string)
+	for indexName, fieldIndexes := range s.indexFields {
+		// Fields left without a value by a nil pointer have no entry
+		if len(fieldIndexes) == 1 {
+			if values, ok := s.fieldIndexValues(structValue, indexName); ok {
+				result[indexName] = values
+			}
+			continue
+		}
+		// Composite values join the escaped field values like composite keys
+		parts := make([]string, len(fieldIndexes))
+		for index, fieldIndex := range fieldIndexes {
+			fieldValue, ok := s.fieldValue(structValue, fieldIndex)
+			if !ok {
+				parts = nil
+				break
+			}
+			parts[index] = escapeKeyPart(s.collate(fieldIndex, encodeIndexValue(fieldValue)))
+		}
+		if parts != nil {
+			result[indexName] = []string{strings.Join(parts, "\x00")}
+		}
+	}
+	s.extractFullTextValues(value, result)
+	return result
+}
+
+// indexOperations lists the index changes from the index values of a record to new ones,
+// where nil values stand for a record that does not exist
+func (s *Store[T]) indexOperations(oldValues, newValues map[string][]string) []indexOperation {
+	// Full-text indexes hold values beyond the secondary indexes, so every index with values is compared
+	names := make(map[string]bool, len(oldValues)+len(newValues))
+	for name := range oldValues {
+		names[name] = true
+	}
+	for name := range newValues {
+		names[name] = true
+	}
+	var operations []indexOperation
+	for name := range names {
+		removed := subtractIndexValues(oldValues[name], newValues[name])
+		added := subtractIndexValues(newValues[name], oldValues[name])
+		// A changed single value is replaced in one operation
+		if len(removed) <= 1 && len(added) <= 1 {
+			if len(removed) == 0 && len(added) == 0 {
+				continue
+			}
+			operation := indexOperation{IndexName: name}
+			if len(removed) == 1 {
+				operation.OldValue = removed[0]
+			}
+			if len(added) == 1 {
+				operation.NewValue = added[0]
+			}
+			operations = append(operations, operation)
+			continue
+		}
+		for _, value := range removed {
+			operations = append(operations, indexOperation{IndexName: name, OldValue: value})
+		}
+		for _, value := range added {
+			operations = append(operations, indexOperation{IndexName: name, NewValue: value})
+		}
+	}
+	return operations
+}
+
+// subtractIndexValues returns the values of an index missing from other values, leaving out empty values as they
+// have no entry
+func subtractIndexValues(values, other []string) []string {
+	var result []string
+	for _, value := range values {
+		found := value == ""
+		for _, otherValue := range other {
+			if value == otherValue {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, value)
 		}
 	}
 	return result
@@ -0,0 +1,401 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type AggregateFunction int
+
+const (
+	Count AggregateFunction = iota // Number of records, or of records holding a value for the field when one is given
+	Sum                            // Sum of the values of a numeric field
+	Avg                            // Average of the values of a numeric field
+	Min                            // Smallest value of a field
+	Max                            // Largest value of a field
+)
+
+// Aggregation computes a value over the records of each group
+type Aggregation struct {
+	Function AggregateFunction
+	Field    string
+}
+
+// AggregateGroup holds the results of the aggregations over a group of records
+type AggregateGroup struct {
+	Values  []interface{} // Values of the GroupBy fields shared by the records of the group, nil where missing
+	Results []interface{} // Result of each aggregation in order, an int for Count, a float64 for Sum and Avg, and a field value for Min and Max
+}
+
+// aggregator accumulates the values of an aggregation
+type aggregator struct {
+	count int
+	sum   float64
+	value interface{} // smallest or largest value so far
+}
+
+// Aggregate computes the aggregations over the records selected by the query, with a group for each distinct
+// combination of values of the GroupBy fields, sorted by those values, or a single group without GroupBy
+func (s *Store[T]) Aggregate(ctx context.Context, query *Query, aggregations ...Aggregation) ([]AggregateGroup, error) {
+	if err := s.validateAggregate(query, aggregations); err != nil {
+		return nil, err
+	}
+	var groups []AggregateGroup
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		groups, err = s.In(snapshot).Aggregate(ctx, query, aggregations...)
+		return err
+	})
+	return groups, err
+}
+
+// Aggregate computes the aggregations over the records selected by the query as of the snapshot
+func (v *StoreView[T]) Aggregate(ctx context.Context, query *Query, aggregations ...Aggregation) ([]AggregateGroup, error) {
+	if err := v.store.validateAggregate(query, aggregations); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	if !v.snapshot.exists(v.store.bucket) {
+		return nil, BucketNotFoundError{Bucket: string(v.store.bucket)}
+	}
+
+	// Queries over every record can be answered from the indexes alone
+	if v.store.selectsAll(query) {
+		if groups, ok := v.aggregateFromIndex(query, aggregations); ok {
+			return groups, nil
+		}
+	}
+
+	selectQuery := *query
+	selectQuery.GroupBy = nil
+	keys, err := v.getQueryKeys(&selectQuery)
+	if err != nil {
+		return nil, err
+	}
+	groupFields := v.store.groupFields(query)
+	groups := make(map[string]*AggregateGroup)
+	aggregators := make(map[string][]aggregator)
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for _, key := range keys {
+		data := v.snapshot.get(v.store.bucket, []byte(key))
+		if data == nil {
+			continue
+		}
+		var item T
+		decoder.Reset(bytes.NewReader(data))
+		if err := decoder.Decode(&item); err != nil {
+			return nil, WrappedError{Operation: "decode", Bucket: string(v.store.bucket), Key: v.store.formatKey(key), Err: err}
+		}
+		itemValue := reflect.ValueOf(item)
+		values := v.store.sortValues(itemValue, groupFields)
+		groupKey := v.store.groupKey(groupFields, values)
+		if groups[groupKey] == nil {
+			groups[groupKey] = &AggregateGroup{}
+			if len(groupFields) > 0 {
+				groups[groupKey].Values = values
+			}
+			aggregators[groupKey] = make([]aggregator, len(aggregations))
+		}
+		for position, aggregation := range aggregations {
+			v.store.accumulate(&aggregators[groupKey][position], itemValue, aggregation)
+		}
+	}
+
+	// A query without GroupBy always has its single group, even when no records match
+	if len(groupFields) == 0 && len(groups) == 0 {
+		groups[""] = &AggregateGroup{}
+		aggregators[""] = make([]aggregator, len(aggregations))
+	}
+	return finishGroups(groups, aggregators, aggregations), nil
+}
+
+// validateAggregate validates an aggregate query and its aggregations
+func (s *Store[T]) validateAggregate(query *Query, aggregations []Aggregation) error {
+	if query == nil {
+		return InvalidQueryError{Field: "query", Value: nil, Reason: "cannot be nil"}
+	}
+	selectQuery := *query
+	selectQuery.GroupBy = nil
+	if err := s.validateQuery(&selectQuery); err != nil {
+		return err
+	}
+	for _, field := range query.GroupBy {
+		fieldIndex, exists := s.resolveField(field)
+		if !exists {
+			return InvalidQueryError{Field: "GroupBy", Value: field, Reason: "field does not exist"}
+		}
+		if _, ok := indexValueWidth(s.fields[fieldIndex].fieldType); !ok {
+			return InvalidQueryError{Field: "GroupBy", Value: field, Reason: "must be a string, number, bool or time field"}
+		}
+	}
+	if len(aggregations) == 0 {
+		return InvalidQueryError{Field: "Aggregation", Value: aggregations, Reason: "at least one is required"}
+	}
+	for _, aggregation := range aggregations {
+		if aggregation.Function == Count && aggregation.Field == "" {
+			continue
+		}
+		fieldIndex, exists := s.resolveField(aggregation.Field)
+		if !exists {
+			return InvalidQueryError{Field: "Aggregation.Field", Value: aggregation.Field, Reason: "field does not exist"}
+		}
+		fieldType := s.fields[fieldIndex].fieldType
+		switch aggregation.Function {
+		case Count, Min, Max:
+			if _, ok := indexValueWidth(fieldType); !ok {
+				return InvalidQueryError{Field: "Aggregation.Field", Value: aggregation.Field, Reason: "must be a string, number, bool or time field"}
+			}
+		case Sum, Avg:
+			if fieldType == timeType || !isNumericKind(fieldType.Kind()) {
+				return InvalidQueryError{Field: "Aggregation.Field", Value: aggregation.Field, Reason: "must be a numeric field"}
+			}
+		default:
+			return InvalidQueryError{Field: "Aggregation.Function", Value: aggregation.Function, Reason: "is not an aggregate function"}
+		}
+	}
+	return nil
+}
+
+// isNumericKind reports whether a kind holds integers or floating point numbers
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// groupFields returns the fields the records of a query are grouped by
+func (s *Store[T]) groupFields(query *Query) []int {
+	fieldIndexes := make([]int, len(query.GroupBy))
+	for position, field := range query.GroupBy {
+		fieldIndexes[position], _ = s.resolveField(field)
+	}
+	return fieldIndexes
+}
+
+// groupKey converts the values of a group into a string identifying it
+func (s *Store[T]) groupKey(fieldIndexes []int, values []interface{}) string {
+	parts := make([]string, len(values))
+	for position, value := range values {
+		// Missing values are kept apart from every present value
+		if encoded, ok := s.encodeFieldValue(fieldIndexes[position], value); ok {
+			parts[position] = escapeKeyPart("\x01" + encoded)
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// selectsAll reports whether a query selects every record that is not soft-deleted
+func (s *Store[T]) selectsAll(query *Query) bool {
+	return !query.hasFilter() && len(query.KeyPrefix) == 0 && query.Limit == 0 && query.Offset == 0 &&
+		query.After == "" && query.Before == "" && !query.IncludeDeleted
+}
+
+// accumulate adds the value of a record for an aggregation to its aggregator
+func (s *Store[T]) accumulate(a *aggregator, item reflect.Value, aggregation Aggregation) {
+	if aggregation.Function == Count && aggregation.Field == "" {
+		a.count++
+		return
+	}
+	fieldIndex, _ := s.resolveField(aggregation.Field)
+	fieldValue, ok := s.fieldValue(item, fieldIndex)
+	if !ok {
+		return
+	}
+	a.count++
+	value := fieldValue.Interface()
+	switch aggregation.Function {
+	case Sum, Avg:
+		number, _ := floatValue(fieldValue)
+		a.sum += number
+	case Min:
+		if a.value == nil || compare(value, a.value) < 0 {
+			a.value = value
+		}
+	case Max:
+		if a.value == nil || compare(value, a.value) > 0 {
+			a.value = value
+		}
+	}
+}
+
+// result returns the result of an aggregation from its accumulated values
+func (a aggregator) result(aggregation Aggregation) interface{} {
+	switch aggregation.Function {
+	case Count:
+		return a.count
+	case Sum:
+		return a.sum
+	case Avg:
+		if a.count == 0 {
+			return nil
+		}
+		return a.sum / float64(a.count)
+	}
+	return a.value
+}
+
+// finishGroups computes the results of the groups, sorted by their values
+func finishGroups(groups map[string]*AggregateGroup, aggregators map[string][]aggregator, aggregations []Aggregation) []AggregateGroup {
+	results := make([]AggregateGroup, 0, len(groups))
+	for groupKey, group := range groups {
+		group.Results = make([]interface{}, len(aggregations))
+		for position, aggregation := range aggregations {
+			group.Results[position] = aggregators[groupKey][position].result(aggregation)
+		}
+		results = append(results, *group)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		for position := range results[i].Values {
+			if comparison := compareSortValues(results[i].Values[position], results[j].Values[position]); comparison != 0 {
+				return comparison < 0
+			}
+		}
+		return false
+	})
+	return results
+}
+
+// aggregateFromIndex answers aggregations over every record from the indexes, reporting false when they cannot be:
+// Min and Max on an indexed field without GroupBy read the first or last entry, and counts grouped by an indexed
+// field count the entries of each value
+func (v *StoreView[T]) aggregateFromIndex(query *Query, aggregations []Aggregation) ([]AggregateGroup, bool) {
+	if len(query.GroupBy) == 1 {
+		return v.countGroupsFromIndex(query, aggregations)
+	}
+	if len(query.GroupBy) > 0 {
+		return nil, false
+	}
+
+	results := make([]interface{}, len(aggregations))
+	for position, aggregation := range aggregations {
+		switch aggregation.Function {
+		case Min, Max:
+			fieldIndex, _ := v.store.resolveField(aggregation.Field)
+			index, ok := v.store.orderedIndex(fieldIndex)
+			if !ok {
+				return nil, false
+			}
+			value, ok := v.indexBoundValue(index, fieldIndex, aggregation.Function == Max)
+			if !ok {
+				return nil, false
+			}
+			results[position] = value
+		case Count:
+			if aggregation.Field != "" {
+				return nil, false
+			}
+			results[position] = v.countAllKeys() - v.countDeleted()
+		default:
+			return nil, false
+		}
+	}
+	return []AggregateGroup{{Results: results}}, true
+}
+
+// orderedIndex returns the single-field index of a field whose order matches the order of the field values
+func (s *Store[T]) orderedIndex(fieldIndex int) (string, bool) {
+	index, ok := s.indexOfField[fieldIndex]
+	if !ok || s.multiValue[index] {
+		return "", false
+	}
+	// Collated strings are ordered by their normalised form
+	if _, collated := s.collations[fieldIndex]; collated {
+		return "", false
+	}
+	return index, true
+}
+
+// indexBoundValue returns the smallest or largest value of a field from the first or last entry of its index that
+// is not soft-deleted, or nil when there is none. Records without a value are missing from the index, just as
+// they are left out of Min and Max, reporting false when empty strings could be missing from it as well.
+func (v *StoreView[T]) indexBoundValue(index string, fieldIndex int, largest bool) (interface{}, bool) {
+	// Empty strings have no index entry, yet are the smallest value of a field
+	valueType, _, _ := indexedType(v.store.fields[fieldIndex].fieldType)
+	if valueType.Kind() == reflect.String && v.countKeysFromIndex(index) != v.countAllKeys() {
+		return nil, false
+	}
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	keep := func(key string) bool {
+		return v.snapshot.get(tombstoneBucket, []byte(key)) == nil
+	}
+	keys := v.walkIndexForExpression(index, largest, nil, nil, keep, 1)
+	if len(keys) == 0 {
+		return nil, true
+	}
+	item, err := v.get(keys[0])
+	if err != nil {
+		return nil, false
+	}
+	value, ok := v.store.fieldValue(reflect.ValueOf(item), fieldIndex)
+	if !ok {
+		return nil, true
+	}
+	return value.Interface(), true
+}
+
+// countGroupsFromIndex counts the records grouped by a single indexed field from the entries of its index,
+// reporting false when other aggregations are requested or records without a value could be missed
+func (v *StoreView[T]) countGroupsFromIndex(query *Query, aggregations []Aggregation) ([]AggregateGroup, bool) {
+	fieldIndex, _ := v.store.resolveField(query.GroupBy[0])
+	index, ok := v.store.indexOfField[fieldIndex]
+	if !ok || v.store.multiValue[index] {
+		return nil, false
+	}
+	for _, aggregation := range aggregations {
+		if aggregation.Function != Count {
+			return nil, false
+		}
+		// Every record holds a value for the grouped field once all of them are in its index
+		if countField, _ := v.store.resolveField(aggregation.Field); aggregation.Field != "" && countField != fieldIndex {
+			return nil, false
+		}
+	}
+	if v.countKeysFromIndex(index) != v.countAllKeys() {
+		return nil, false
+	}
+
+	// Each value is read from the first record holding it, as the index holds its encoded form
+	var groups []AggregateGroup
+	var counts []int
+	var lastValue []byte
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	layout := v.store.indexLayout(index)
+	cursor := v.snapshot.cursor([]byte(string(v.store.bucket) + "_index_" + index))
+	for keyBytes, _ := cursor.First(); keyBytes != nil; keyBytes, _ = cursor.Next() {
+		valueBytes, key, ok := layout.split(keyBytes)
+		if !ok || v.snapshot.get(tombstoneBucket, key) != nil {
+			continue
+		}
+		if len(groups) == 0 || !bytes.Equal(valueBytes, lastValue) {
+			item, err := v.get(string(key))
+			if err != nil {
+				return nil, false
+			}
+			groups = append(groups, AggregateGroup{Values: v.store.sortValues(reflect.ValueOf(item), []int{fieldIndex})})
+			counts = append(counts, 0)
+			lastValue = append(lastValue[:0], valueBytes...)
+		}
+		counts[len(counts)-1]++
+	}
+	for position := range groups {
+		groups[position].Results = make([]interface{}, len(aggregations))
+		for result := range aggregations {
+			groups[position].Results[result] = counts[position]
+		}
+	}
+	return groups, true
+}
@@ -0,0 +1,322 @@
+package nnut
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type TestSale struct {
+	ID       string `nnut:"key"`
+	Region   string `nnut:"index:region"`
+	Product  string
+	Amount   float64 `nnut:"index:amount"`
+	Quantity int
+	Discount *int `nnut:"index:discount"`
+}
+
+func TestAggregate(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestSale](db, "sales", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	regions := []string{"north", "south", "east"}
+	products := []string{"tea", "coffee"}
+	var sales []TestSale
+	for i := 0; i < 30; i++ {
+		sale := TestSale{
+			ID:       fmt.Sprintf("%02d", i),
+			Region:   regions[i%len(regions)],
+			Product:  products[i%len(products)],
+			Amount:   float64(i*7%11) - 3.5,
+			Quantity: i % 4,
+		}
+		if i%5 == 0 {
+			discount := i
+			sale.Discount = &discount
+		}
+		sales = append(sales, sale)
+	}
+	err = store.PutBatch(context.Background(), sales[:15])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), sales[15:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Delete(context.Background(), "04")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	sales = append(sales[:4], sales[5:]...)
+
+	// expected aggregates the sales matching keep the slow way, grouped by region and product when grouped is set
+	expected := func(keep func(TestSale) bool, grouped bool) []AggregateGroup {
+		type result struct {
+			count, quantities, discounts int
+			sum                          float64
+			minAmount, maxAmount         interface{}
+			maxDiscount                  interface{}
+		}
+		var order []string
+		results := make(map[string]*result)
+		values := make(map[string][]interface{})
+		for _, sale := range sales {
+			if !keep(sale) {
+				continue
+			}
+			groupKey := ""
+			if grouped {
+				groupKey = sale.Region + "/" + sale.Product
+			}
+			if results[groupKey] == nil {
+				results[groupKey] = &result{}
+				order = append(order, groupKey)
+				if grouped {
+					values[groupKey] = []interface{}{sale.Region, sale.Product}
+				}
+			}
+			r := results[groupKey]
+			r.count++
+			r.quantities += sale.Quantity
+			r.sum += sale.Amount
+			if r.minAmount == nil || sale.Amount < r.minAmount.(float64) {
+				r.minAmount = sale.Amount
+			}
+			if r.maxAmount == nil || sale.Amount > r.maxAmount.(float64) {
+				r.maxAmount = sale.Amount
+			}
+			if sale.Discount != nil {
+				r.discounts++
+				if r.maxDiscount == nil || *sale.Discount > r.maxDiscount.(int) {
+					r.maxDiscount = *sale.Discount
+				}
+			}
+		}
+		if !grouped && len(order) == 0 {
+			results[""] = &result{}
+			order = append(order, "")
+		}
+		var groups []AggregateGroup
+		for _, groupKey := range order {
+			r := results[groupKey]
+			var avg interface{}
+			if r.count > 0 {
+				avg = r.sum / float64(r.count)
+			}
+			groups = append(groups, AggregateGroup{
+				Values:  values[groupKey],
+				Results: []interface{}{r.count, float64(r.quantities), avg, r.minAmount, r.maxAmount, r.discounts, r.maxDiscount},
+			})
+		}
+		for i := range groups {
+			for j := i + 1; j < len(groups); j++ {
+				if fmt.Sprint(groups[j].Values) < fmt.Sprint(groups[i].Values) {
+					groups[i], groups[j] = groups[j], groups[i]
+				}
+			}
+		}
+		return groups
+	}
+	aggregations := []Aggregation{
+		{Function: Count},
+		{Function: Sum, Field: "Quantity"},
+		{Function: Avg, Field: "Amount"},
+		{Function: Min, Field: "Amount"},
+		{Function: Max, Field: "Amount"},
+		{Function: Count, Field: "Discount"},
+		{Function: Max, Field: "Discount"},
+	}
+
+	tests := []struct {
+		name    string
+		query   *Query
+		keep    func(TestSale) bool
+		grouped bool
+	}{
+		{"all", &Query{}, func(TestSale) bool { return true }, false},
+		{"all without index", &Query{Limit: 100}, func(TestSale) bool { return true }, false},
+		{"filtered", &Query{Conditions: []Condition{{Field: "Region", Value: "south"}}}, func(sale TestSale) bool { return sale.Region == "south" }, false},
+		{"no matches", &Query{Conditions: []Condition{{Field: "Region", Value: "west"}}}, func(TestSale) bool { return false }, false},
+		{"grouped", &Query{GroupBy: []string{"Region", "Product"}}, func(TestSale) bool { return true }, true},
+		{"grouped and filtered", &Query{GroupBy: []string{"Region", "Product"}, Conditions: []Condition{{Field: "Quantity", Value: 2, Operator: GreaterThanOrEqual}}}, func(sale TestSale) bool { return sale.Quantity >= 2 }, true},
+	}
+	for _, test := range tests {
+		groups, err := store.Aggregate(context.Background(), test.query, aggregations...)
+		if err != nil {
+			t.Fatalf("%s: Failed to aggregate: %v", test.name, err)
+		}
+		if want := expected(test.keep, test.grouped); !reflect.DeepEqual(groups, want) {
+			t.Errorf("%s: Expected %v, got %v", test.name, want, groups)
+		}
+	}
+
+	// Min and Max on indexed fields come from the ends of the index, and match those of the records
+	bounds := []Aggregation{
+		{Function: Min, Field: "Amount"},
+		{Function: Max, Field: "Amount"},
+		{Function: Min, Field: "Discount"},
+		{Function: Max, Field: "Discount"},
+		{Function: Count},
+	}
+	want := []AggregateGroup{{Results: []interface{}{-3.5, 6.5, 0, 25, 29}}}
+	for _, query := range []*Query{{}, {Limit: 100}} {
+		groups, err := store.Aggregate(context.Background(), query, bounds...)
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if !reflect.DeepEqual(groups, want) {
+			t.Errorf("Expected %v for %+v, got %v", want, query, groups)
+		}
+	}
+
+	// Group counts on an indexed field come from the index, and match those of the records
+	for _, query := range []*Query{{GroupBy: []string{"Region"}}, {GroupBy: []string{"Region"}, Limit: 100}} {
+		groups, err := store.Aggregate(context.Background(), query, Aggregation{Function: Count}, Aggregation{Function: Count, Field: "Region"})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		want := []AggregateGroup{
+			{Values: []interface{}{"east"}, Results: []interface{}{10, 10}},
+			{Values: []interface{}{"north"}, Results: []interface{}{10, 10}},
+			{Values: []interface{}{"south"}, Results: []interface{}{9, 9}},
+		}
+		if !reflect.DeepEqual(groups, want) {
+			t.Errorf("Expected %v for %+v, got %v", want, query, groups)
+		}
+	}
+
+	// Records without a value are grouped together and sort first
+	groups, err := store.Aggregate(context.Background(), &Query{GroupBy: []string{"Discount"}}, Aggregation{Function: Count})
+	if err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if len(groups) != 7 || groups[0].Values[0] != nil || groups[0].Results[0] != 23 || groups[1].Values[0] != 0 {
+		t.Errorf("Expected records without a discount in the first group, got %v", groups)
+	}
+}
+
+func TestAggregateEmptyIndexValue(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	aggregations := []Aggregation{{Function: Min, Field: "Name"}, {Function: Max, Field: "Name"}}
+	queries := []*Query{{}, {Conditions: []Condition{{Field: "Age", Value: 0, Operator: GreaterThan}}}}
+
+	// Empty strings have no index entry, yet count as values of the field on every path
+	for _, names := range [][]string{{"", "bob", "zed"}, {""}} {
+		users := make([]TestUser, len(names))
+		for position, name := range names {
+			users[position] = TestUser{UUID: fmt.Sprintf("%d%d", len(names), position), Name: name, Age: 20 + position}
+		}
+		err = store.PutBatch(context.Background(), users)
+		if err != nil {
+			t.Fatalf("Failed to put batch: %v", err)
+		}
+		for _, query := range queries {
+			groups, err := store.Aggregate(context.Background(), query, aggregations...)
+			if err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+			want := []AggregateGroup{{Results: []interface{}{"", names[len(names)-1]}}}
+			if !reflect.DeepEqual(groups, want) {
+				t.Errorf("Expected %v for %+v, got %v", want, query, groups)
+			}
+		}
+		err = store.DeleteBatch(context.Background(), []string{"30", "31", "32"})
+		if err != nil {
+			t.Fatalf("Failed to delete batch: %v", err)
+		}
+	}
+
+	// Without empty strings the index answers, and agrees with the records
+	err = store.PutBatch(context.Background(), []TestUser{{UUID: "a", Name: "bob", Age: 1}, {UUID: "b", Name: "zed", Age: 2}})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Delete(context.Background(), "10")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	for _, query := range queries {
+		groups, err := store.Aggregate(context.Background(), query, aggregations...)
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		want := []AggregateGroup{{Results: []interface{}{"bob", "zed"}}}
+		if !reflect.DeepEqual(groups, want) {
+			t.Errorf("Expected %v for %+v, got %v", want, query, groups)
+		}
+	}
+}
+
+func TestAggregateInvalid(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestSale](db, "sales")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	tests := []struct {
+		query        *Query
+		aggregations []Aggregation
+	}{
+		{nil, []Aggregation{{Function: Count}}},
+		{&Query{}, nil},
+		{&Query{}, []Aggregation{{Function: Sum, Field: "Region"}}},
+		{&Query{}, []Aggregation{{Function: Avg}}},
+		{&Query{}, []Aggregation{{Function: Max, Field: "Missing"}}},
+		{&Query{}, []Aggregation{{Function: AggregateFunction(99), Field: "Amount"}}},
+		{&Query{GroupBy: []string{"Missing"}}, []Aggregation{{Function: Count}}},
+		{&Query{Limit: -1}, []Aggregation{{Function: Count}}},
+	}
+	for _, test := range tests {
+		_, err := store.Aggregate(context.Background(), test.query, test.aggregations...)
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Errorf("Expected InvalidQueryError for %+v %+v, got %v", test.query, test.aggregations, err)
+		}
+	}
+
+	// GroupBy is only used by Aggregate
+	_, err = store.GetQuery(context.Background(), &Query{GroupBy: []string{"Region"}})
+	if _, ok := err.(InvalidQueryError); !ok {
+		t.Errorf("Expected InvalidQueryError for GroupBy in GetQuery, got %v", err)
+	}
+}
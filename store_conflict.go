@@ -0,0 +1,68 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+)
+
+// maxWriteAttempts bounds how often a write starts over when the records it depends on change while it runs
+const maxWriteAttempts = 8
+
+// recordRead is a copy of a record as read before a write, to tell whether it changed before the write
+type recordRead struct {
+	key       string
+	data      []byte
+	deletedAt int64
+}
+
+// readRecords returns copies of the records with the keys against the stored and pending writes
+func (s *Store[T]) readRecords(ctx context.Context, keys []string) ([]recordRead, error) {
+	var reads []recordRead
+	err := s.database.view(ctx, s.bucket, keys, func(snapshot *Snapshot) error {
+		reads = s.In(snapshot).readRecords(keys)
+		return nil
+	})
+	return reads, err
+}
+
+// readRecords returns copies of the records with the keys as of the snapshot, leaving out keys not found
+func (v *StoreView[T]) readRecords(keys []string) []recordRead {
+	var reads []recordRead
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	for _, key := range keys {
+		data := v.snapshot.get(v.store.bucket, []byte(key))
+		if data == nil {
+			continue
+		}
+		reads = append(reads, recordRead{
+			key:       key,
+			data:      append([]byte(nil), data...),
+			deletedAt: decodeTombstone(v.snapshot.get(tombstoneBucket, []byte(key))),
+		})
+	}
+	return reads
+}
+
+// checkUnchanged returns a write check failing with a ConflictError when any of the records read has changed
+func (s *Store[T]) checkUnchanged(reads []recordRead) func(*Snapshot) error {
+	return func(snapshot *Snapshot) error {
+		tombstoneBucket := tombstoneBucketName(s.bucket)
+		for _, read := range reads {
+			data := snapshot.get(s.bucket, []byte(read.key))
+			if data == nil || !bytes.Equal(data, read.data) || decodeTombstone(snapshot.get(tombstoneBucket, []byte(read.key))) != read.deletedAt {
+				return ConflictError{Bucket: string(s.bucket), Key: s.formatKey(read.key)}
+			}
+		}
+		return nil
+	}
+}
+
+// retryConflicts runs a write until it no longer fails with a ConflictError, at most maxWriteAttempts times
+func retryConflicts(write func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if _, conflict := err.(ConflictError); !conflict || attempt >= maxWriteAttempts {
+			return err
+		}
+	}
+}
@@ -2,41 +2,27 @@ package nnut
 
 import (
 	"context"
-
-	"go.etcd.io/bbolt"
 )
 
 // Count returns the total number of items in the store
 func (s *Store[T]) Count(ctx context.Context) (int, error) {
 	var count int
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		count, err = s.In(snapshot).Count(ctx)
+		return err
+	})
+	return count, err
+}
+
+// Count returns the total number of items in the store as of the snapshot
+func (v *StoreView[T]) Count(ctx context.Context) (int, error) {
 	select {
 	case <-ctx.Done():
 		return 0, ctx.Err()
 	default:
 	}
-	err := s.database.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(s.bucket)
-		if bucket == nil {
-			count = 0
-			return nil
-		}
-		count = bucket.Stats().KeyN
-
-		// Adjust for buffered operations
-		bufferedOps := s.database.getBufferedOperationsForBucket(s.bucket)
-		for _, op := range bufferedOps {
-			// op.Key is the actual key
-			exists := bucket.Get([]byte(op.Key)) != nil
-			if op.IsPut && !exists {
-				count++ // New key being added
-			} else if !op.IsPut && exists {
-				count-- // Existing key being deleted
-			}
-		}
-
-		return nil
-	})
-	return count, err
+	return v.countAllKeys() - v.countDeleted(), nil
 }
 
 // CountQuery returns the number of records matching the query
@@ -44,29 +30,60 @@ func (s *Store[T]) CountQuery(ctx context.Context, query *Query) (int, error) {
 	if err := s.validateQuery(query); err != nil {
 		return 0, err
 	}
-
 	var count int
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		count, err = s.In(snapshot).CountQuery(ctx, query)
+		return err
+	})
+	return count, err
+}
+
+// CountQuery returns the number of records matching the query as of the snapshot
+func (v *StoreView[T]) CountQuery(ctx context.Context, query *Query) (int, error) {
+	if err := v.store.validateQuery(query); err != nil {
+		return 0, err
+	}
 	select {
 	case <-ctx.Done():
 		return 0, ctx.Err()
 	default:
 	}
-	err := s.database.View(func(tx *bbolt.Tx) error {
-		// Collect candidate keys from conditions
-		var candidateKeys []string
-		if len(query.Conditions) > 0 {
-			candidateKeys = s.getCandidateKeysTx(tx, query.Conditions, 0)
-		} else if query.Index != "" {
+
+	// Collect candidate keys from conditions
+	excludeDeleted := !query.IncludeDeleted && v.hasDeleted()
+	var candidateKeys []string
+	if len(query.KeyPrefix) > 0 || query.After != "" || query.Before != "" {
+		// Count the keys sharing the prefix or past the cursor, with limit and offset ignored
+		prefixQuery := *query
+		prefixQuery.Limit = 0
+		prefixQuery.Offset = 0
+		keys, err := v.getQueryKeys(&prefixQuery)
+		if _, ok := err.(BucketNotFoundError); ok {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return len(keys), nil
+	} else if query.hasFilter() {
+		candidateKeys = v.getFilterKeys(query, 0)
+	} else if query.Index != "" {
+		if !excludeDeleted {
 			// No conditions, but index, count from index
-			count = s.countKeysFromIndexTx(tx, query.Index)
-			return nil
-		} else {
-			// No conditions, no index, count all keys
-			count = s.countAllKeysTx(tx)
-			return nil
+			return v.countKeysFromIndex(query.Index), nil
 		}
-		count = len(candidateKeys)
-		return nil
-	})
-	return count, err
+		candidateKeys = v.getKeysFromIndex(query.Index, query.Sort, 0)
+	} else {
+		// No conditions, no index, count all keys
+		count := v.countAllKeys()
+		if excludeDeleted {
+			count -= v.countDeleted()
+		}
+		return count, nil
+	}
+	if excludeDeleted {
+		candidateKeys = v.filterDeleted(candidateKeys)
+	}
+	return len(candidateKeys), nil
 }
@@ -3,41 +3,33 @@ package nnut
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/vmihailenco/msgpack/v5"
-	"go.etcd.io/bbolt"
 )
 
 // Delete removes a value by key
-func (s *Store[T]) Delete(ctx context.Context, key string) error {
-	if err := validateKey(key); err != nil {
+func (s *Store[T]) Delete(ctx context.Context, key interface{}) error {
+	encodedKey, err := s.encodeKey(key)
+	if err != nil {
 		return err
 	}
+	if s.softDelete {
+		return s.softDeleteBatch(ctx, []string{encodedKey})
+	}
 	// Retrieve existing value to update indexes correctly
-	var oldIndexValues map[string]string
-	oldValue, err := s.Get(ctx, key)
+	var oldIndexValues map[string][]string
+	oldValue, err := s.get(ctx, encodedKey)
 	if err == nil {
 		oldIndexValues = s.extractIndexValues(oldValue)
-	} else {
-		oldIndexValues = make(map[string]string)
 	}
 
 	// Set up index removals for each deleted item
-	var indexOperations []indexOperation
-	for name := range s.indexFields {
-		oldValue := oldIndexValues[name]
-		if oldValue != "" {
-			indexOperations = append(indexOperations, indexOperation{
-				IndexName: name,
-				OldValue:  oldValue,
-				NewValue:  "",
-			})
-		}
-	}
+	indexOperations := s.indexOperations(oldIndexValues, nil)
 
 	operation := operation{
 		Bucket:          s.bucket,
-		Key:             key,
+		Key:             encodedKey,
 		Value:           nil,
 		IsPut:           false,
 		IndexOperations: indexOperations,
@@ -46,37 +38,33 @@ func (s *Store[T]) Delete(ctx context.Context, key string) error {
 	return s.database.writeOperation(ctx, operation)
 }
 
-// DeleteBatch removes multiple values by keys
-func (s *Store[T]) DeleteBatch(ctx context.Context, keys []string) error {
+// DeleteBatch removes multiple values by a slice of keys
+func (s *Store[T]) DeleteBatch(ctx context.Context, keys interface{}) error {
+	encodedKeys, err := s.encodeKeys(keys)
+	if err != nil {
+		return err
+	}
+	if s.softDelete {
+		return s.softDeleteBatch(ctx, encodedKeys)
+	}
+
 	// Fetch current values to handle index updates in batch
-	oldValues, err := s.GetBatch(ctx, keys)
+	oldValues, err := s.getBatch(ctx, encodedKeys, false)
 	if err != nil {
 		return WrappedError{Operation: "get_batch", Bucket: string(s.bucket), Err: err}
 	}
 
 	// Build operations for each key to be deleted
 	var operations []operation
-	for _, key := range keys {
+	for _, key := range encodedKeys {
 		oldValue, exists := oldValues[key]
-		var oldIndexValues map[string]string
+		var oldIndexValues map[string][]string
 		if exists {
 			oldIndexValues = s.extractIndexValues(oldValue)
-		} else {
-			oldIndexValues = make(map[string]string)
 		}
 
 		// Prepare index updates for deletion
-		var indexOperations []indexOperation
-		for name := range s.indexFields {
-			oldValue := oldIndexValues[name]
-			if oldValue != "" {
-				indexOperations = append(indexOperations, indexOperation{
-					IndexName: name,
-					OldValue:  oldValue,
-					NewValue:  "",
-				})
-			}
-		}
+		indexOperations := s.indexOperations(oldIndexValues, nil)
 
 		operation := operation{
 			Bucket:          s.bucket,
@@ -91,82 +79,89 @@ func (s *Store[T]) DeleteBatch(ctx context.Context, keys []string) error {
 	return s.database.writeOperations(ctx, operations)
 }
 
-// DeleteQuery deletes records matching the query conditions and returns the count of deleted records
+// DeleteQuery deletes records matching the query conditions and returns the count of deleted records.
+// The records are only deleted when none of them changed since they were read, otherwise the query starts over.
 func (s *Store[T]) DeleteQuery(ctx context.Context, query *Query) (int, error) {
 	if err := s.validateQuery(query); err != nil {
 		return 0, err
 	}
 
-	var deletedCount int
-	select {
-	case <-ctx.Done():
-		return 0, ctx.Err()
-	default:
-	}
-	err := s.database.Update(func(tx *bbolt.Tx) error {
-		// Gather keys that potentially match the query conditions
-		var candidateKeys []string
-		if len(query.Conditions) > 0 {
-			candidateKeys = s.getCandidateKeysTx(tx, query.Conditions, 0)
-		} else if query.Index != "" {
-			// When no conditions but sorting is required, use the index directly
-			candidateKeys = s.getKeysFromIndexTx(tx, query.Index, query.Sort, 0)
-		} else {
-			// Fallback to scanning all keys when no optimizations apply
-			candidateKeys = s.getAllKeysTx(tx, 0)
+	var operations []operation
+	var failed map[string]error
+	err := retryConflicts(func() error {
+		reads, err := s.readForDelete(ctx, query)
+		if err != nil {
+			return err
 		}
+		operations, failed = s.deleteOperations(reads)
+		return s.database.writeCheckedOperations(ctx, operations, s.checkUnchanged(reads))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(failed) > 0 {
+		return len(operations), PartialBatchError{SuccessfulCount: len(operations), Failed: failed}
+	}
+	return len(operations), nil
+}
 
-		// Apply offset and limit to candidate keys
-		start := query.Offset
-		if start > len(candidateKeys) {
-			start = len(candidateKeys)
-		}
-		end := len(candidateKeys)
-		if query.Limit > 0 && start+query.Limit < end {
-			end = start + query.Limit
+// readForDelete returns copies of the records matching the query against the stored and pending writes
+func (s *Store[T]) readForDelete(ctx context.Context, query *Query) ([]recordRead, error) {
+	resolveQuery := *query
+	if s.softDelete {
+		// Records that are already soft-deleted cannot be soft-deleted again
+		resolveQuery.IncludeDeleted = false
+	}
+	var reads []recordRead
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		view := s.In(snapshot)
+		keysToDelete, err := view.getQueryKeys(&resolveQuery)
+		if err != nil {
+			return err
 		}
-		keysToDelete := candidateKeys[start:end]
+		reads = view.readRecords(keysToDelete)
+		return nil
+	})
+	return reads, err
+}
 
-		// Retrieve the actual data for the selected keys to get old index values
-		bucket := tx.Bucket(s.bucket)
-		if bucket == nil {
-			return BucketNotFoundError{Bucket: string(s.bucket)}
+// deleteOperations returns the operations deleting the records read, and the errors of records that cannot be
+func (s *Store[T]) deleteOperations(reads []recordRead) ([]operation, map[string]error) {
+	var operations []operation
+	failed := make(map[string]error)
+	deletedAt := time.Now().UnixNano()
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for _, read := range reads {
+		// Mark records with a tombstone and keep their index entries for a later restore
+		if s.softDelete {
+			operations = append(operations, operation{
+				Bucket:    s.bucket,
+				Key:       read.key,
+				Value:     read.data,
+				IsPut:     true,
+				DeletedAt: deletedAt,
+			})
+			continue
 		}
-		decoder := msgpack.GetDecoder()
-		defer msgpack.PutDecoder(decoder)
-
-		for _, key := range keysToDelete {
-			data := bucket.Get([]byte(key))
-			if data == nil {
-				continue
-			}
-			var item T
-			decoder.Reset(bytes.NewReader(data))
-			err := decoder.Decode(&item)
-			if err != nil {
-				continue
-			}
-			// Delete the record
-			if err := bucket.Delete([]byte(key)); err != nil {
-				continue
-			}
-			// Update indexes by removing old index entries
-			oldIndexValues := s.extractIndexValues(item)
-			for indexName, value := range oldIndexValues {
-				indexBucketName := string(s.bucket) + "_index_" + indexName
-				indexBucket := tx.Bucket([]byte(indexBucketName))
-				if indexBucket != nil {
-					indexKey := value + "\x00" + key
-					indexBucket.Delete([]byte(indexKey))
-				}
-			}
-			deletedCount++
+
+		var item T
+		decoder.Reset(bytes.NewReader(read.data))
+		err := decoder.Decode(&item)
+		if err != nil {
+			displayKey := s.formatKey(read.key)
+			failed[displayKey] = WrappedError{Operation: "decode", Bucket: string(s.bucket), Key: displayKey, Err: err}
+			continue
 		}
-		return nil
-	})
-	if err != nil {
-		return deletedCount, err
+		// Remove the old index entries together with the record
+		indexOperations := s.indexOperations(s.extractIndexValues(item), nil)
+		operations = append(operations, operation{
+			Bucket:          s.bucket,
+			Key:             read.key,
+			Value:           nil,
+			IsPut:           false,
+			IndexOperations: indexOperations,
+		})
 	}
-
-	return deletedCount, nil
+	return operations, failed
 }
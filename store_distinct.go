@@ -0,0 +1,218 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// DistinctOptions controls the order and number of the values returned by Distinct
+type DistinctOptions struct {
+	Sort  Sorting // Ascending (default) or Descending order of the values
+	Limit int     // Maximum number of values to return (0 = no limit)
+
+	IncludeDeleted bool // Include the values of soft-deleted records
+}
+
+// Distinct returns the distinct values of an index in the order of the index, read from the index alone.
+// Values of composite indexes are slices of the values of their fields, and collated strings are normalised.
+func (s *Store[T]) Distinct(ctx context.Context, index string, opts *DistinctOptions) ([]interface{}, error) {
+	var values []interface{}
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		values, err = s.In(snapshot).Distinct(ctx, index, opts)
+		return err
+	})
+	return values, err
+}
+
+// Distinct returns the distinct values of an index as of the snapshot
+func (v *StoreView[T]) Distinct(ctx context.Context, index string, opts *DistinctOptions) ([]interface{}, error) {
+	if opts == nil {
+		opts = &DistinctOptions{}
+	}
+	resolved, exists := v.store.resolveIndex(index)
+	if !exists {
+		return nil, InvalidQueryError{Field: "Index", Value: index, Reason: "index field does not exist"}
+	}
+	if opts.Limit < 0 {
+		return nil, InvalidQueryError{Field: "Limit", Value: opts.Limit, Reason: "cannot be negative"}
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	if !v.snapshot.exists(v.store.bucket) {
+		return nil, BucketNotFoundError{Bucket: string(v.store.bucket)}
+	}
+
+	descending := opts.Sort == Descending
+	excludeDeleted := !opts.IncludeDeleted && v.hasDeleted()
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	layout := v.store.indexLayout(resolved)
+	cursor := v.snapshot.cursor([]byte(string(v.store.bucket) + "_index_" + resolved))
+
+	var values []interface{}
+	keyBytes, _ := seekPast(cursor, nil, descending)
+	for keyBytes != nil && (opts.Limit == 0 || len(values) < opts.Limit) {
+		value, key, ok := layout.split(keyBytes)
+		if !ok || excludeDeleted && v.snapshot.get(tombstoneBucket, key) != nil {
+			keyBytes, _ = stepCursor(cursor, descending)
+			continue
+		}
+		values = append(values, v.store.decodeIndexValue(resolved, value))
+		keyBytes = skipIndexValue(cursor, value, descending)
+	}
+	return values, nil
+}
+
+// skipIndexValue moves the cursor past the remaining entries of an index value in the walk direction
+func skipIndexValue(cursor *overlayCursor, value []byte, descending bool) []byte {
+	// Entries hold the value followed by the separator, so they all sort between the value followed by the
+	// separator and the value followed by a higher byte
+	seek := append(append([]byte(nil), value...), 0x01)
+	if descending {
+		seek[len(seek)-1] = 0x00
+		cursor.Seek(seek)
+		keyBytes, _ := cursor.Prev()
+		return keyBytes
+	}
+	keyBytes, _ := cursor.Seek(seek)
+	return keyBytes
+}
+
+// Facets counts the records matching the query for each value of the indexes, keyed by index as given.
+// Records count once for each element of a multi-value index, and records without a value, or with an empty
+// string, are left out.
+func (s *Store[T]) Facets(ctx context.Context, query *Query, indexes ...string) (map[string]map[interface{}]int, error) {
+	if err := s.validateFacets(query, indexes); err != nil {
+		return nil, err
+	}
+	var facets map[string]map[interface{}]int
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		facets, err = s.In(snapshot).Facets(ctx, query, indexes...)
+		return err
+	})
+	return facets, err
+}
+
+// Facets counts the records matching the query for each value of the indexes as of the snapshot
+func (v *StoreView[T]) Facets(ctx context.Context, query *Query, indexes ...string) (map[string]map[interface{}]int, error) {
+	if err := v.store.validateFacets(query, indexes); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	if !v.snapshot.exists(v.store.bucket) {
+		return nil, BucketNotFoundError{Bucket: string(v.store.bucket)}
+	}
+
+	// Queries over every record count the entries of each index without looking up the matches
+	var keys map[string]bool
+	if !v.store.selectsAll(query) {
+		queryKeys, err := v.getQueryKeys(query)
+		if err != nil {
+			return nil, err
+		}
+		keys = make(map[string]bool, len(queryKeys))
+		for _, key := range queryKeys {
+			keys[key] = true
+		}
+	}
+
+	facets := make(map[string]map[interface{}]int, len(indexes))
+	for _, index := range indexes {
+		resolved, _ := v.store.resolveIndex(index)
+		var counts map[string]int
+		if keys != nil && len(keys)*facetDecodeRatio < v.countKeysFromIndex(resolved) {
+			counts = v.countValuesFromRecords(resolved, keys)
+		} else {
+			counts = v.countValuesFromIndex(resolved, keys)
+		}
+		facets[index] = make(map[interface{}]int, len(counts))
+		for encoded, count := range counts {
+			facets[index][v.store.decodeIndexValue(resolved, []byte(encoded))] = count
+		}
+	}
+	return facets, nil
+}
+
+// facetDecodeRatio is about the number of index entries walked in the time a record is decoded. Few matches
+// compared with the entries of an index are counted from the records instead of walking the whole index.
+const facetDecodeRatio = 16
+
+// validateFacets validates a facet query and its indexes
+func (s *Store[T]) validateFacets(query *Query, indexes []string) error {
+	if err := s.validateQuery(query); err != nil {
+		return err
+	}
+	if len(indexes) == 0 {
+		return InvalidQueryError{Field: "Index", Value: indexes, Reason: "at least one is required"}
+	}
+	for _, index := range indexes {
+		resolved, exists := s.resolveIndex(index)
+		if !exists {
+			return InvalidQueryError{Field: "Index", Value: index, Reason: "index field does not exist"}
+		}
+		// Values of composite indexes are slices, which cannot key a map
+		if len(s.indexFields[resolved]) > 1 {
+			return InvalidQueryError{Field: "Index", Value: index, Reason: "composite indexes cannot be counted by value"}
+		}
+	}
+	return nil
+}
+
+// countValuesFromIndex counts the entries of an index by value, of the records with the keys when not nil,
+// or of all records that are not soft-deleted otherwise
+func (v *StoreView[T]) countValuesFromIndex(index string, keys map[string]bool) map[string]int {
+	excludeDeleted := keys == nil && v.hasDeleted()
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	layout := v.store.indexLayout(index)
+	cursor := v.snapshot.cursor([]byte(string(v.store.bucket) + "_index_" + index))
+
+	counts := make(map[string]int)
+	for keyBytes, _ := cursor.First(); keyBytes != nil; keyBytes, _ = cursor.Next() {
+		value, key, ok := layout.split(keyBytes)
+		if !ok {
+			continue
+		}
+		if keys != nil && !keys[string(key)] || excludeDeleted && v.snapshot.get(tombstoneBucket, key) != nil {
+			continue
+		}
+		counts[string(value)]++
+	}
+	return counts
+}
+
+// countValuesFromRecords counts the records with the keys by their values in a single field index
+func (v *StoreView[T]) countValuesFromRecords(index string, keys map[string]bool) map[string]int {
+	counts := make(map[string]int)
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for key := range keys {
+		data := v.snapshot.get(v.store.bucket, []byte(key))
+		if data == nil {
+			continue
+		}
+		var item T
+		decoder.Reset(bytes.NewReader(data))
+		if err := decoder.Decode(&item); err != nil {
+			continue
+		}
+		// Empty values have no index entry, so they are left out as when counting from the index
+		values, _ := v.store.fieldIndexValues(reflect.ValueOf(item), index)
+		for _, encoded := range values {
+			if encoded != "" {
+				counts[encoded]++
+			}
+		}
+	}
+	return counts
+}
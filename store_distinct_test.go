@@ -0,0 +1,280 @@
+package nnut
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestListing struct {
+	ID       string    `nnut:"key"`
+	Brand    string    `nnut:"index:brand,fold"`
+	Price    float64   `nnut:"index:price"`
+	Stock    *int      `nnut:"index:stock"`
+	Tags     []string  `nnut:"index:tags"`
+	Listed   time.Time `nnut:"index:listed"`
+	Category string    `nnut:"index:category_price:1"`
+	Grade    int8      `nnut:"index:category_price:2"`
+}
+
+func TestDistinct(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestListing](db, "listings", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	brands := []string{"Acme", "acme", "Globex", "Initech"}
+	var listings []TestListing
+	for i := 0; i < 24; i++ {
+		listing := TestListing{
+			ID:       fmt.Sprintf("%02d", i),
+			Brand:    brands[i%len(brands)],
+			Price:    float64(i%5) - 1.5,
+			Tags:     []string{"new", "sale", "eco"}[:i%3+1],
+			Listed:   base.AddDate(0, 0, -(i % 3)),
+			Category: []string{"toys", "tools"}[i%2],
+			Grade:    int8(i%3) - 1,
+		}
+		if i%4 == 0 {
+			stock := i
+			listing.Stock = &stock
+		}
+		listings = append(listings, listing)
+	}
+	err = store.PutBatch(context.Background(), listings[:12])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), listings[12:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// Initech is only listed by records 03, 07, 11, 15, 19 and 23, the last of which is soft-deleted
+	for _, key := range []string{"03", "07", "11", "15", "19"} {
+		err = store.Delete(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+	}
+
+	tests := []struct {
+		index    string
+		opts     *DistinctOptions
+		expected []interface{}
+	}{
+		{"brand", nil, []interface{}{"acme", "globex", "initech"}},
+		{"Brand", &DistinctOptions{Sort: Descending}, []interface{}{"initech", "globex", "acme"}},
+		{"brand", &DistinctOptions{Limit: 2}, []interface{}{"acme", "globex"}},
+		{"price", &DistinctOptions{Sort: Descending}, []interface{}{2.5, 1.5, 0.5, -0.5, -1.5}},
+		{"stock", nil, []interface{}{0, 4, 8, 12, 16, 20}},
+		{"tags", nil, []interface{}{"eco", "new", "sale"}},
+		{"listed", nil, []interface{}{base.AddDate(0, 0, -2), base.AddDate(0, 0, -1), base}},
+		{"category_price", &DistinctOptions{Limit: 4}, []interface{}{
+			[]interface{}{"tools", int8(-1)}, []interface{}{"tools", int8(0)}, []interface{}{"tools", int8(1)},
+			[]interface{}{"toys", int8(-1)},
+		}},
+	}
+	for _, test := range tests {
+		values, err := store.Distinct(context.Background(), test.index, test.opts)
+		if err != nil {
+			t.Fatalf("Failed to get distinct values of %s: %v", test.index, err)
+		}
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("Expected %v for %s %+v, got %v", test.expected, test.index, test.opts, values)
+		}
+	}
+
+	// Soft-deleted records keep their index entries until purged
+	err = store.Delete(context.Background(), "23")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	values, err := store.Distinct(context.Background(), "brand", nil)
+	if err != nil {
+		t.Fatalf("Failed to get distinct values: %v", err)
+	}
+	if !reflect.DeepEqual(values, []interface{}{"acme", "globex"}) {
+		t.Errorf("Expected values of soft-deleted records to be left out, got %v", values)
+	}
+	values, err = store.Distinct(context.Background(), "brand", &DistinctOptions{IncludeDeleted: true, Sort: Descending})
+	if err != nil {
+		t.Fatalf("Failed to get distinct values: %v", err)
+	}
+	if !reflect.DeepEqual(values, []interface{}{"initech", "globex", "acme"}) {
+		t.Errorf("Expected values of soft-deleted records to be included, got %v", values)
+	}
+
+	for _, index := range []string{"missing", "ID"} {
+		_, err = store.Distinct(context.Background(), index, nil)
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Errorf("Expected InvalidQueryError for %s, got %v", index, err)
+		}
+	}
+}
+
+func TestFacets(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestListing](db, "listings", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	brands := []string{"Acme", "acme", "Globex", "Initech"}
+	var listings []TestListing
+	for i := 0; i < 200; i++ {
+		listing := TestListing{
+			ID:    fmt.Sprintf("%03d", i),
+			Brand: brands[i%len(brands)],
+			Price: float64(i % 5),
+			Tags:  []string{"new", "sale", "eco"}[:i%3+1],
+		}
+		if i%10 == 0 {
+			stock := i % 20
+			listing.Stock = &stock
+		}
+		listings = append(listings, listing)
+	}
+	err = store.PutBatch(context.Background(), listings[:100])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), listings[100:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Delete(context.Background(), "000")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	listings = listings[1:]
+
+	// expected counts the values of the listings matching keep the slow way
+	expected := func(keep func(TestListing) bool) map[string]map[interface{}]int {
+		facets := map[string]map[interface{}]int{"brand": {}, "Price": {}, "stock": {}, "tags": {}}
+		for _, listing := range listings {
+			if !keep(listing) {
+				continue
+			}
+			facets["brand"][map[string]string{"Acme": "acme", "acme": "acme", "Globex": "globex", "Initech": "initech"}[listing.Brand]]++
+			facets["Price"][listing.Price]++
+			if listing.Stock != nil {
+				facets["stock"][*listing.Stock]++
+			}
+			for _, tag := range listing.Tags {
+				facets["tags"][tag]++
+			}
+		}
+		return facets
+	}
+
+	tests := []struct {
+		name  string
+		query *Query
+		keep  func(TestListing) bool
+	}{
+		{"all", &Query{}, func(TestListing) bool { return true }},
+		{"most", &Query{Conditions: []Condition{{Field: "Price", Value: 0.0, Operator: GreaterThan}}}, func(listing TestListing) bool { return listing.Price > 0 }},
+		{"few", &Query{Conditions: []Condition{{Field: "ID", Value: "19", Operator: HasPrefix}}}, func(listing TestListing) bool { return listing.ID[:2] == "19" }},
+		{"none", &Query{Conditions: []Condition{{Field: "Brand", Value: "Umbrella"}}}, func(TestListing) bool { return false }},
+		{"limited", &Query{Index: "price", Limit: 30}, func(listing TestListing) bool { return listing.Price == 0 && listing.ID <= "150" }},
+	}
+	for _, test := range tests {
+		facets, err := store.Facets(context.Background(), test.query, "brand", "Price", "stock", "tags")
+		if err != nil {
+			t.Fatalf("%s: Failed to get facets: %v", test.name, err)
+		}
+		if want := expected(test.keep); !reflect.DeepEqual(facets, want) {
+			t.Errorf("%s: Expected %v, got %v", test.name, want, facets)
+		}
+	}
+
+	invalid := []struct {
+		query   *Query
+		indexes []string
+	}{
+		{nil, []string{"brand"}},
+		{&Query{}, nil},
+		{&Query{}, []string{"missing"}},
+		{&Query{}, []string{"category_price"}},
+		{&Query{Limit: -1}, []string{"brand"}},
+	}
+	for _, test := range invalid {
+		_, err := store.Facets(context.Background(), test.query, test.indexes...)
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Errorf("Expected InvalidQueryError for %+v %v, got %v", test.query, test.indexes, err)
+		}
+	}
+}
+
+func TestFacetsEmptyIndexValue(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	users := []TestUser{{UUID: "unnamed", Age: 99}}
+	for i := 0; i < 40; i++ {
+		users = append(users, TestUser{UUID: fmt.Sprintf("%02d", i), Name: []string{"Alice", "Bob"}[i%2], Age: i})
+	}
+	err = store.PutBatch(context.Background(), users)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// Few matches are counted from the records and many from the index, leaving out empty values either way
+	tests := []struct {
+		query    *Query
+		expected map[interface{}]int
+	}{
+		{&Query{Conditions: []Condition{{Field: "Age", Value: 99}}}, map[interface{}]int{}},
+		{&Query{Conditions: []Condition{{Field: "Age", Value: 38, Operator: GreaterThanOrEqual}}}, map[interface{}]int{"Alice": 1, "Bob": 1}},
+		{&Query{Conditions: []Condition{{Field: "Age", Value: 0, Operator: GreaterThanOrEqual}}}, map[interface{}]int{"Alice": 20, "Bob": 20}},
+		{&Query{}, map[interface{}]int{"Alice": 20, "Bob": 20}},
+	}
+	for _, test := range tests {
+		facets, err := store.Facets(context.Background(), test.query, "name")
+		if err != nil {
+			t.Fatalf("Failed to get facets: %v", err)
+		}
+		if !reflect.DeepEqual(facets["name"], test.expected) {
+			t.Errorf("Expected %v for %+v, got %v", test.expected, test.query.Conditions, facets["name"])
+		}
+	}
+}
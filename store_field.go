@@ -0,0 +1,80 @@
+package nnut
+
+import (
+	"reflect"
+	"strings"
+)
+
+// storeField describes a field of the record type or of a struct nested in it
+type storeField struct {
+	name      string       // dotted path of field names from the record type, as in Address.City
+	index     []int        // field indexes along the path
+	fieldType reflect.Type // type of the field, without the pointer of pointer fields
+	tag       string       // nnut tag of the field
+}
+
+// collectFields lists the fields of a struct type and of the structs nested in it, and maps their dotted paths to
+// their position in the list. Fields promoted from embedded structs are also mapped by their own name.
+func collectFields(structType reflect.Type) ([]storeField, map[string]int) {
+	var fields []storeField
+	fieldMap := make(map[string]int)
+	var walk func(structType reflect.Type, prefix string, index []int, visiting map[reflect.Type]bool)
+	walk = func(structType reflect.Type, prefix string, index []int, visiting map[reflect.Type]bool) {
+		visiting[structType] = true
+		defer delete(visiting, structType)
+		for fieldIndex := 0; fieldIndex < structType.NumField(); fieldIndex++ {
+			field := structType.Field(fieldIndex)
+			if !field.IsExported() && !field.Anonymous {
+				continue
+			}
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			path := append(append([]int(nil), index...), fieldIndex)
+			if field.IsExported() {
+				fieldMap[prefix+field.Name] = len(fields)
+				fields = append(fields, storeField{name: prefix + field.Name, index: path, fieldType: fieldType, tag: field.Tag.Get("nnut")})
+			}
+			// Nested structs are walked once per path, so recursive types end where they repeat
+			if fieldType.Kind() == reflect.Struct && fieldType != timeType && !visiting[fieldType] {
+				walk(fieldType, prefix+field.Name+".", path, visiting)
+			}
+		}
+	}
+	walk(structType, "", nil, make(map[reflect.Type]bool))
+
+	// Promoted names follow the rules of the language, so shadowed and ambiguous names are left out
+	for position, field := range fields {
+		name := field.name[strings.LastIndex(field.name, ".")+1:]
+		if _, exists := fieldMap[name]; exists {
+			continue
+		}
+		if promoted, ok := structType.FieldByName(name); ok && reflect.DeepEqual(promoted.Index, field.index) {
+			fieldMap[name] = position
+		}
+	}
+	return fields, fieldMap
+}
+
+// fieldValue returns the value of a field of a record, following the pointers along its path, or false when a nil
+// pointer leaves the field without a value
+func (s *Store[T]) fieldValue(record reflect.Value, fieldIndex int) (reflect.Value, bool) {
+	value := record
+	for _, index := range s.fields[fieldIndex].index {
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(index)
+	}
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return reflect.Value{}, false
+		}
+		value = value.Elem()
+	}
+	return value, true
+}
@@ -0,0 +1,146 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type TestAudit struct {
+	CreatedAt time.Time `nnut:"index:CreatedAt"`
+}
+
+type TestAddress struct {
+	Street string
+	City   string `nnut:"index:Address.City"`
+}
+
+type TestBillingAddress struct {
+	City string `nnut:"index:Billing.City"`
+}
+
+type TestCustomer struct {
+	ID string `nnut:"key"`
+	TestAudit
+	Address  TestAddress
+	Billing  *TestBillingAddress
+	Nickname *string `nnut:"index:Nickname"`
+}
+
+func TestNestedFields(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestCustomer](db, "customers")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Promoted fields are found by their own name and by their path
+	if store.fieldMap["CreatedAt"] != store.fieldMap["TestAudit.CreatedAt"] {
+		t.Fatalf("Expected CreatedAt to be promoted from TestAudit")
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	nickname := "bob"
+	customers := []TestCustomer{
+		{ID: "a", TestAudit: TestAudit{CreatedAt: base}, Address: TestAddress{Street: "Main", City: "Paris"}},
+		{ID: "b", TestAudit: TestAudit{CreatedAt: base.Add(time.Hour)}, Address: TestAddress{Street: "High", City: "Rome"}, Billing: &TestBillingAddress{City: "Oslo"}, Nickname: &nickname},
+		{ID: "c", TestAudit: TestAudit{CreatedAt: base.Add(2 * time.Hour)}, Address: TestAddress{Street: "Main", City: "Rome"}, Billing: &TestBillingAddress{City: "Bern"}},
+	}
+	err = store.PutBatch(context.Background(), customers[:1])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), customers[1:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	ids := func(results []TestCustomer) string {
+		var ids string
+		for _, result := range results {
+			ids += result.ID
+		}
+		return ids
+	}
+
+	for _, test := range []struct {
+		conditions []Condition
+		expected   string
+	}{
+		{[]Condition{{Field: "Address.City", Value: "Rome"}}, "bc"},
+		{[]Condition{{Field: "Address.City", Value: "Rome"}, {Field: "Address.Street", Value: "Main"}}, "c"},
+		{[]Condition{{Field: "Billing.City", Value: "Oslo"}}, "b"},
+		{[]Condition{{Field: "Billing.City", Value: "A", Operator: GreaterThan}}, "bc"},
+		{[]Condition{{Field: "CreatedAt", Value: base, Operator: GreaterThan}}, "bc"},
+		{[]Condition{{Field: "TestAudit.CreatedAt", Value: base.Add(time.Hour), Operator: LessThanOrEqual}}, "ab"},
+		{[]Condition{{Field: "Nickname", Value: "bob"}}, "b"},
+	} {
+		results, err := store.GetQuery(context.Background(), &Query{Conditions: test.conditions})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if ids(results) != test.expected {
+			t.Fatalf("Expected %s for %+v, got %s", test.expected, test.conditions, ids(results))
+		}
+	}
+
+	// Records whose path crosses a nil pointer have no entry in the index
+	results, err := store.GetQuery(context.Background(), &Query{Index: "Billing.City", Sort: Ascending})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "cb" {
+		t.Fatalf("Expected cb, got %s", ids(results))
+	}
+
+	// Conditions on unknown paths are rejected
+	_, err = store.GetQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Address.Country", Value: "France"}}})
+	if _, ok := err.(InvalidQueryError); !ok {
+		t.Fatalf("Expected InvalidQueryError, got %v", err)
+	}
+}
+
+func TestRecursiveFields(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type node struct {
+		ID     string `nnut:"key"`
+		Name   string `nnut:"index:Name"`
+		Parent *node
+	}
+	store, err := NewStore[node](db, "nodes")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.Put(context.Background(), node{ID: "child", Name: "leaf", Parent: &node{ID: "root", Name: "root"}})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	results, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Name", Value: "leaf"}}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "child" {
+		t.Fatalf("Expected child, got %+v", results)
+	}
+}
@@ -0,0 +1,262 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters controlling term frequency saturation and document length normalisation
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// FullTextConfig holds the text analysis options of full-text indexes
+type FullTextConfig struct {
+	Tokenizer func(text string) []string // Splits text into tokens, by default lower-cased runs of letters and digits
+	StopWords map[string]bool            // Tokens left out of the index, such as "the" and "and"
+	Stemmer   func(token string) string  // Reduces tokens to their stem, leaving them as they are by default
+}
+
+// SearchOptions holds the options of a full-text search
+type SearchOptions struct {
+	Fields         []string // Full-text fields to search, all of them when empty
+	Limit          int      // Maximum number of results to return (0 = no limit)
+	Offset         int      // Number of results to skip
+	IncludeDeleted bool     // Include soft-deleted records in the results
+}
+
+// SearchResult holds a record found by a full-text search together with its BM25 relevance score
+type SearchResult[T any] struct {
+	Item  T
+	Score float64
+}
+
+// defaultTokenizer splits text into lower-cased runs of letters and digits
+func defaultTokenizer(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// fullTextIndexName returns the name of the postings of the full-text index on a field. Index tags cannot hold
+// colons, so the name does not collide with secondary indexes.
+func fullTextIndexName(fieldPath string) string {
+	return "fulltext:" + fieldPath
+}
+
+// fullTextLengthName returns the name of the index holding the number of terms of each record in a full-text index
+func fullTextLengthName(index string) string {
+	return index + ":length"
+}
+
+// analyze turns text into the terms of a full-text index
+func (s *Store[T]) analyze(text string) []string {
+	tokenizer := s.fullTextConfig.Tokenizer
+	if tokenizer == nil {
+		tokenizer = defaultTokenizer
+	}
+	var terms []string
+	for _, token := range tokenizer(text) {
+		if s.fullTextConfig.StopWords[token] {
+			continue
+		}
+		if s.fullTextConfig.Stemmer != nil {
+			token = s.fullTextConfig.Stemmer(token)
+		}
+		if token != "" {
+			terms = append(terms, token)
+		}
+	}
+	return terms
+}
+
+// extractFullTextValues adds the postings of the full-text indexes of a record to its index values. Each term of
+// the text has an entry holding the term, its frequency and the number of terms of the text, joined like the
+// values of composite indexes.
+func (s *Store[T]) extractFullTextValues(record T, values map[string][]string) {
+	structValue := reflect.ValueOf(record)
+	for index, fieldIndex := range s.fullText {
+		fieldValue, ok := s.fieldValue(structValue, fieldIndex)
+		if !ok {
+			continue
+		}
+		terms := s.analyze(fieldValue.String())
+		if len(terms) == 0 {
+			continue
+		}
+		frequencies := make(map[string]uint64)
+		for _, term := range terms {
+			frequencies[term]++
+		}
+		length := escapeKeyPart(encodeUnsigned(uint64(len(terms))))
+		postings := make([]string, 0, len(frequencies))
+		for term, frequency := range frequencies {
+			postings = append(postings, escapeKeyPart(term)+"\x00"+escapeKeyPart(encodeUnsigned(frequency))+"\x00"+length)
+		}
+		sort.Strings(postings)
+		values[index] = postings
+		values[fullTextLengthName(index)] = []string{encodeUnsigned(uint64(len(terms)))}
+	}
+}
+
+// Search finds the records whose full-text fields contain any of the terms of the text, ranked by BM25 relevance
+func (s *Store[T]) Search(ctx context.Context, text string, opts SearchOptions) ([]SearchResult[T], error) {
+	if _, err := s.searchIndexes(opts); err != nil {
+		return nil, err
+	}
+	var results []SearchResult[T]
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		results, err = s.In(snapshot).Search(ctx, text, opts)
+		return err
+	})
+	return results, err
+}
+
+// Search finds the records whose full-text fields contain any of the terms of the text as of the snapshot,
+// ranked by BM25 relevance
+func (v *StoreView[T]) Search(ctx context.Context, text string, opts SearchOptions) ([]SearchResult[T], error) {
+	indexes, err := v.store.searchIndexes(opts)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	terms := make(map[string]bool)
+	for _, term := range v.store.analyze(text) {
+		terms[term] = true
+	}
+	scores := make(map[string]float64)
+	for _, index := range indexes {
+		v.scoreFullText(index, terms, scores)
+	}
+
+	// Rank by score, breaking ties by key so the order is stable
+	keys := make([]string, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	if !opts.IncludeDeleted && v.hasDeleted() {
+		keys = v.filterDeleted(keys)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if opts.Offset >= len(keys) {
+		return nil, nil
+	}
+	keys = keys[opts.Offset:]
+	if opts.Limit > 0 && len(keys) > opts.Limit {
+		keys = keys[:opts.Limit]
+	}
+
+	records, err := v.getBatch(keys, true)
+	results := make([]SearchResult[T], 0, len(keys))
+	for _, key := range keys {
+		if record, ok := records[key]; ok {
+			results = append(results, SearchResult[T]{Item: record, Score: scores[key]})
+		}
+	}
+	return results, err
+}
+
+// searchIndexes returns the full-text indexes searched with the options
+func (s *Store[T]) searchIndexes(opts SearchOptions) ([]string, error) {
+	if opts.Limit < 0 {
+		return nil, InvalidQueryError{Field: "Limit", Value: opts.Limit, Reason: "cannot be negative"}
+	}
+	if opts.Offset < 0 {
+		return nil, InvalidQueryError{Field: "Offset", Value: opts.Offset, Reason: "cannot be negative"}
+	}
+	var indexes []string
+	if len(opts.Fields) == 0 {
+		for index := range s.fullText {
+			indexes = append(indexes, index)
+		}
+		sort.Strings(indexes)
+		return indexes, nil
+	}
+	for _, field := range opts.Fields {
+		fieldIndex, ok := s.resolveField(field)
+		if !ok {
+			return nil, InvalidQueryError{Field: "Fields", Value: field, Reason: "field does not exist"}
+		}
+		index := fullTextIndexName(s.fields[fieldIndex].name)
+		if _, ok := s.fullText[index]; !ok {
+			return nil, InvalidQueryError{Field: "Fields", Value: field, Reason: "not a full-text field"}
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// scoreFullText adds the BM25 scores of the records holding the terms in a full-text index to the scores
+func (v *StoreView[T]) scoreFullText(index string, terms map[string]bool, scores map[string]float64) {
+	// Collection statistics come from the number of terms of each record
+	var documents, totalLength float64
+	lengths := v.snapshot.cursor([]byte(string(v.store.bucket) + "_index_" + fullTextLengthName(index)))
+	for entry, _ := lengths.First(); entry != nil; entry, _ = lengths.Next() {
+		if len(entry) < 8 {
+			continue
+		}
+		documents++
+		totalLength += float64(binary.BigEndian.Uint64(entry[:8]))
+	}
+	if documents == 0 {
+		return
+	}
+	averageLength := totalLength / documents
+
+	layout := indexLayout{parts: 3}
+	postings := v.snapshot.cursor([]byte(string(v.store.bucket) + "_index_" + index))
+	for term := range terms {
+		type posting struct {
+			key       string
+			frequency float64
+			length    float64
+		}
+		var matches []posting
+		prefix := []byte(escapeKeyPart(term) + "\x00")
+		for entry, _ := postings.Seek(prefix); entry != nil && bytes.HasPrefix(entry, prefix); entry, _ = postings.Next() {
+			value, key, ok := layout.split(entry)
+			if !ok {
+				continue
+			}
+			parts := bytes.Split(value[len(prefix):], []byte{0x00})
+			if len(parts) != 2 {
+				continue
+			}
+			frequency := unescapeKeyPart(string(parts[0]))
+			length := unescapeKeyPart(string(parts[1]))
+			if len(frequency) != 8 || len(length) != 8 {
+				continue
+			}
+			matches = append(matches, posting{
+				key:       string(key),
+				frequency: float64(binary.BigEndian.Uint64([]byte(frequency))),
+				length:    float64(binary.BigEndian.Uint64([]byte(length))),
+			})
+		}
+
+		// Rare terms weigh more, and repeated terms count less the more often they occur in a long text
+		idf := math.Log(1 + (documents-float64(len(matches))+0.5)/(float64(len(matches))+0.5))
+		for _, match := range matches {
+			normalization := bm25K1 * (1 - bm25B + bm25B*match.length/averageLength)
+			scores[match.key] += idf * match.frequency * (bm25K1 + 1) / (match.frequency + normalization)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type TestNote struct {
+	ID    string `nnut:"key"`
+	Title string `nnut:"fulltext"`
+	Body  string `nnut:"fulltext"`
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestNote](db, "notes", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	notes := []TestNote{
+		{ID: "a", Title: "Quick fox", Body: "The quick brown fox jumps over the lazy dog."},
+		{ID: "b", Title: "Dogs", Body: "A lazy dog sleeps all day, every day, in the sun by the old barn."},
+		{ID: "c", Title: "Cats", Body: "Cats ignore the fox."},
+		{ID: "d", Title: "Birds", Body: "Nothing to see here."},
+	}
+	err = store.PutBatch(context.Background(), notes[:2])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), notes[2:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	ids := func(results []SearchResult[TestNote]) string {
+		var ids string
+		for _, result := range results {
+			ids += result.Item.ID
+		}
+		return ids
+	}
+
+	// Records holding more of the terms, and rarer terms, rank higher
+	results, err := store.Search(context.Background(), "Quick FOX", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if ids(results) != "ac" {
+		t.Fatalf("Expected ac, got %s", ids(results))
+	}
+	if results[0].Score <= results[1].Score || results[1].Score <= 0 {
+		t.Fatalf("Expected decreasing positive scores, got %v and %v", results[0].Score, results[1].Score)
+	}
+
+	// Shorter texts with the same term frequency rank higher
+	results, err = store.Search(context.Background(), "lazy", SearchOptions{Fields: []string{"Body"}})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if ids(results) != "ab" {
+		t.Fatalf("Expected ab, got %s", ids(results))
+	}
+
+	results, err = store.Search(context.Background(), "fox", SearchOptions{Fields: []string{"Title"}, Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if ids(results) != "a" {
+		t.Fatalf("Expected a, got %s", ids(results))
+	}
+
+	// Updates replace the terms of a record
+	notes[2].Body = "Cats chase mice."
+	err = store.Put(context.Background(), notes[2])
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	results, err = store.Search(context.Background(), "fox mice", SearchOptions{Fields: []string{"Body"}})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if !strings.Contains(ids(results), "a") || !strings.Contains(ids(results), "c") || len(results) != 2 {
+		t.Fatalf("Expected a and c, got %s", ids(results))
+	}
+
+	// Soft-deleted records are left out unless included
+	err = store.Delete(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	results, err = store.Search(context.Background(), "fox", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if ids(results) != "" {
+		t.Fatalf("Expected no results, got %s", ids(results))
+	}
+	results, err = store.Search(context.Background(), "fox", SearchOptions{IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if ids(results) != "a" {
+		t.Fatalf("Expected a, got %s", ids(results))
+	}
+
+	for _, opts := range []SearchOptions{{Fields: []string{"ID"}}, {Fields: []string{"Missing"}}, {Limit: -1}} {
+		_, err = store.Search(context.Background(), "fox", opts)
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Fatalf("Expected InvalidQueryError for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestSearchAnalysis(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestNote](db, "notes", &StoreConfig{FullText: FullTextConfig{
+		StopWords: map[string]bool{"the": true},
+		Stemmer: func(token string) string {
+			return strings.TrimSuffix(token, "s")
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.PutBatch(context.Background(), []TestNote{
+		{ID: "a", Body: "The cat"},
+		{ID: "b", Body: "Two cats and the dog"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	results, err := store.Search(context.Background(), "cats", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected stemmed terms to match both notes, got %d", len(results))
+	}
+	results, err = store.Search(context.Background(), "the", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("Expected stop words to match nothing, got %d", len(results))
+	}
+
+	// Deleting removes the terms of the record
+	err = store.Delete(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	db.Flush()
+	err = db.view(context.Background(), store.bucket, nil, func(snapshot *Snapshot) error {
+		if count := snapshot.count([]byte("notes_index_" + fullTextIndexName("Body"))); count != 1 {
+			t.Errorf("Expected 1 posting, got %d", count)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to view: %v", err)
+	}
+}
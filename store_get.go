@@ -5,141 +5,208 @@ import (
 	"context"
 
 	"github.com/vmihailenco/msgpack/v5"
-	"go.etcd.io/bbolt"
 )
 
 // Get retrieves a value by key
-func (s *Store[T]) Get(ctx context.Context, key string) (T, error) {
-	if err := validateKey(key); err != nil {
+func (s *Store[T]) Get(ctx context.Context, key interface{}) (T, error) {
+	encodedKey, err := s.encodeKey(key)
+	if err != nil {
 		var zero T
 		return zero, err
 	}
+	return s.get(ctx, encodedKey)
+}
+
+// get retrieves a value by its stored key
+func (s *Store[T]) get(ctx context.Context, key string) (T, error) {
 	var result T
+	err := s.database.view(ctx, s.bucket, []string{key}, func(snapshot *Snapshot) error {
+		var err error
+		result, err = s.In(snapshot).get(key)
+		return err
+	})
+	return result, err
+}
 
-	// Check buffer for pending changes first
-	if op, exists := s.database.getLatestBufferedOperation(s.bucket, key); exists {
-		if op.IsPut {
-			// Apply buffered put operation
-			decoder := msgpack.GetDecoder()
-			defer msgpack.PutDecoder(decoder)
-			decoder.Reset(bytes.NewReader(op.Value))
-			err := decoder.Decode(&result)
-			if err != nil {
-				return result, WrappedError{Operation: "decode buffered", Bucket: string(s.bucket), Key: key, Err: err}
-			}
-			return result, nil
-		} else {
-			// Buffered delete operation
-			return result, KeyNotFoundError{Bucket: string(s.bucket), Key: key}
-		}
+// GetByKey retrieves a value by the parts of its composite key, in key order
+func (s *Store[T]) GetByKey(ctx context.Context, parts ...interface{}) (T, error) {
+	if len(s.keyParts) == 1 && len(parts) == 1 {
+		return s.Get(ctx, parts[0])
 	}
+	return s.Get(ctx, parts)
+}
 
-	// No buffered changes, query database
+// Get retrieves a value by key as of the snapshot
+func (v *StoreView[T]) Get(ctx context.Context, key interface{}) (T, error) {
+	encodedKey, err := v.store.encodeKey(key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
 	select {
 	case <-ctx.Done():
 		var zero T
 		return zero, ctx.Err()
 	default:
 	}
-	err := s.database.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(s.bucket)
-		if bucket == nil {
-			return BucketNotFoundError{Bucket: string(s.bucket)}
-		}
-		data := bucket.Get([]byte(key))
-		if data == nil {
-			return KeyNotFoundError{Bucket: string(s.bucket), Key: key}
-		}
-		decoder := msgpack.GetDecoder()
-		defer msgpack.PutDecoder(decoder)
-		decoder.Reset(bytes.NewReader(data))
-		err := decoder.Decode(&result)
-		if err != nil {
-			return WrappedError{Operation: "decode", Bucket: string(s.bucket), Key: key, Err: err}
-		}
-		return nil
+	return v.get(encodedKey)
+}
+
+// get retrieves a value by its stored key as of the snapshot
+func (v *StoreView[T]) get(key string) (T, error) {
+	result, deletedAt, err := v.getWithDeleted(key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if deletedAt != 0 {
+		// Soft-deleted records are hidden from regular reads
+		var zero T
+		return zero, KeyNotFoundError{Bucket: string(v.store.bucket), Key: v.store.formatKey(key)}
+	}
+	return result, nil
+}
+
+// getWithDeleted retrieves a value by key including soft-deleted records, returning the deletion timestamp
+func (s *Store[T]) getWithDeleted(ctx context.Context, key string) (T, int64, error) {
+	var result T
+	var deletedAt int64
+	err := s.database.view(ctx, s.bucket, []string{key}, func(snapshot *Snapshot) error {
+		var err error
+		result, deletedAt, err = s.In(snapshot).getWithDeleted(key)
+		return err
 	})
-	return result, err
+	return result, deletedAt, err
 }
 
-// GetBatch retrieves multiple values by keys
-func (s *Store[T]) GetBatch(ctx context.Context, keys []string) (map[string]T, error) {
-	for _, key := range keys {
-		if err := validateKey(key); err != nil {
-			return nil, err
-		}
+// getWithDeleted retrieves a value by key including soft-deleted records, returning the deletion timestamp
+func (v *StoreView[T]) getWithDeleted(key string) (T, int64, error) {
+	var result T
+	data, deletedAt, err := v.getRaw(key)
+	if err != nil {
+		return result, 0, err
 	}
-	results := make(map[string]T)
-	failed := make(map[string]error)
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	decoder.Reset(bytes.NewReader(data))
+	err = decoder.Decode(&result)
+	if err != nil {
+		return result, 0, WrappedError{Operation: "decode", Bucket: string(v.store.bucket), Key: v.store.formatKey(key), Err: err}
+	}
+	return result, deletedAt, nil
+}
 
-	// Check buffer for pending changes first
-	bufferDecoder := msgpack.GetDecoder()
-	defer msgpack.PutDecoder(bufferDecoder)
-	for _, key := range keys {
-		if op, exists := s.database.getLatestBufferedOperation(s.bucket, key); exists {
-			if op.IsPut {
-				var item T
-				bufferDecoder.Reset(bytes.NewReader(op.Value))
-				err := bufferDecoder.Decode(&item)
-				if err != nil {
-					failed[key] = WrappedError{Operation: "decode buffered", Bucket: string(s.bucket), Key: key, Err: err}
-					continue
-				}
-				results[key] = item
-			}
-			// For buffered deletes, don't add to results (treat as not found)
-		} else {
-			// No buffered change, will check DB below
+// getRaw retrieves the encoded value and the soft-delete timestamp of a key
+func (v *StoreView[T]) getRaw(key string) ([]byte, int64, error) {
+	data := v.snapshot.get(v.store.bucket, []byte(key))
+	if data == nil {
+		if !v.snapshot.exists(v.store.bucket) {
+			return nil, 0, BucketNotFoundError{Bucket: string(v.store.bucket)}
 		}
+		return nil, 0, KeyNotFoundError{Bucket: string(v.store.bucket), Key: v.store.formatKey(key)}
+	}
+	deletedAt := decodeTombstone(v.snapshot.get(tombstoneBucketName(v.store.bucket), []byte(key)))
+	return data, deletedAt, nil
+}
+
+// GetBatch retrieves multiple values by a slice of keys of the key field type, or of slices of key parts for
+// composite keys, returning the found values by the readable form of their key, which is the key itself for
+// string keys
+func (s *Store[T]) GetBatch(ctx context.Context, keys interface{}) (map[string]T, error) {
+	encodedKeys, err := s.encodeKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.getBatch(ctx, encodedKeys, false)
+	return s.keyedResults(results), err
+}
+
+// GetBatchByKeys retrieves multiple values by a slice of keys of the key field type, or of slices of key parts
+// for composite keys, returning the values in the order of the keys with nil for those not found
+func (s *Store[T]) GetBatchByKeys(ctx context.Context, keys interface{}) ([]*T, error) {
+	encodedKeys, err := s.encodeKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.getBatch(ctx, encodedKeys, false)
+	return orderedResults(results, encodedKeys), err
+}
+
+// getBatch retrieves multiple values by their stored keys, optionally including soft-deleted records
+func (s *Store[T]) getBatch(ctx context.Context, keys []string, includeDeleted bool) (map[string]T, error) {
+	var results map[string]T
+	err := s.database.view(ctx, s.bucket, keys, func(snapshot *Snapshot) error {
+		var err error
+		results, err = s.In(snapshot).getBatch(keys, includeDeleted)
+		return err
+	})
+	return results, err
+}
+
+// GetBatch retrieves multiple values by a slice of keys of the key field type as of the snapshot
+func (v *StoreView[T]) GetBatch(ctx context.Context, keys interface{}) (map[string]T, error) {
+	encodedKeys, err := v.store.encodeKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
 	}
+	results, err := v.getBatch(encodedKeys, false)
+	return v.store.keyedResults(results), err
+}
 
+// GetBatchByKeys retrieves multiple values by a slice of keys of the key field type as of the snapshot
+func (v *StoreView[T]) GetBatchByKeys(ctx context.Context, keys interface{}) ([]*T, error) {
+	encodedKeys, err := v.store.encodeKeys(keys)
+	if err != nil {
+		return nil, err
+	}
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
 	}
-	err := s.database.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(s.bucket)
-		if bucket == nil {
-			// Missing bucket indicates no data exists - return empty results
-			return nil
+	results, err := v.getBatch(encodedKeys, false)
+	return orderedResults(results, encodedKeys), err
+}
+
+// getBatch retrieves multiple values by their stored keys, optionally including soft-deleted records
+func (v *StoreView[T]) getBatch(keys []string, includeDeleted bool) (map[string]T, error) {
+	results := make(map[string]T)
+	failed := make(map[string]error)
+
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for _, key := range keys {
+		data := v.snapshot.get(v.store.bucket, []byte(key))
+		if data == nil {
+			// Key not found - this is not an error, just missing data
+			continue
 		}
-		decoder := msgpack.GetDecoder()
-		defer msgpack.PutDecoder(decoder)
-		for _, key := range keys {
-			// Skip if already handled by buffer
-			if _, alreadyHandled := results[key]; alreadyHandled {
-				continue
-			}
-			if _, failedKey := failed[key]; failedKey {
-				continue
-			}
-
-			data := bucket.Get([]byte(key))
-			if data != nil {
-				var item T
-				decoder.Reset(bytes.NewReader(data))
-				err := decoder.Decode(&item)
-				if err != nil {
-					// Collect decoding errors for individual items in batch
-					failed[key] = WrappedError{Operation: "decode", Bucket: string(s.bucket), Key: key, Err: err}
-					continue
-				}
-				results[key] = item
-			} else {
-				// Key not found - this is not an error, just missing data
-				// Don't add to failed map for missing keys
-			}
+		if !includeDeleted && v.snapshot.get(tombstoneBucket, []byte(key)) != nil {
+			continue
 		}
-		return nil
-	})
+		var item T
+		decoder.Reset(bytes.NewReader(data))
+		err := decoder.Decode(&item)
+		if err != nil {
+			// Collect decoding errors for individual items in batch
+			displayKey := v.store.formatKey(key)
+			failed[displayKey] = WrappedError{Operation: "decode", Bucket: string(v.store.bucket), Key: displayKey, Err: err}
+			continue
+		}
+		results[key] = item
+	}
 
 	// Only return partial error if there were actual errors (not just missing keys)
-	if err == nil && len(failed) > 0 {
+	if len(failed) > 0 {
 		return results, PartialBatchError{SuccessfulCount: len(results), Failed: failed}
 	}
-
-	return results, err
+	return results, nil
 }
 
 // GetQuery queries for records matching the conditions
@@ -147,73 +214,157 @@ func (s *Store[T]) GetQuery(ctx context.Context, query *Query) ([]T, error) {
 	if err := s.validateQuery(query); err != nil {
 		return nil, err
 	}
-
 	var results []T
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		results, err = s.In(snapshot).GetQuery(ctx, query)
+		return err
+	})
+	return results, err
+}
+
+// GetQuery queries for records matching the conditions as of the snapshot
+func (v *StoreView[T]) GetQuery(ctx context.Context, query *Query) ([]T, error) {
+	if err := v.store.validateQuery(query); err != nil {
+		return nil, err
+	}
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
 	}
-	err := s.database.View(func(tx *bbolt.Tx) error {
-		// Determine the maximum number of keys needed based on limit and offset
-		maxKeys := 0
-		if query.Limit > 0 {
-			maxKeys = query.Offset + query.Limit
+
+	keysToFetch, err := v.getQueryKeys(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve the actual data for the selected keys
+	var results []T
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for _, key := range keysToFetch {
+		data := v.snapshot.get(v.store.bucket, []byte(key))
+		if data == nil {
+			continue
 		}
+		var item T
+		decoder.Reset(bytes.NewReader(data))
+		err := decoder.Decode(&item)
+		if err != nil {
+			continue
+		}
+		results = append(results, item)
+	}
 
-		// Gather keys that potentially match the query conditions
-		var candidateKeys []string
-		if len(query.Conditions) > 0 {
-			candidateKeys = s.getCandidateKeysTx(tx, query.Conditions, maxKeys)
-		} else if query.Index != "" {
-			// When no conditions but sorting is required, use the index directly
-			candidateKeys = s.getKeysFromIndexTx(tx, query.Index, query.Sort, maxKeys)
-		} else {
-			// Fallback to scanning all keys when no optimizations apply
-			candidateKeys = s.getAllKeysTx(tx, maxKeys)
+	return results, nil
+}
+
+// getQueryKeys resolves the keys selected by a query after applying offset and limit
+func (v *StoreView[T]) getQueryKeys(query *Query) ([]string, error) {
+	if !v.snapshot.exists(v.store.bucket) {
+		return nil, BucketNotFoundError{Bucket: string(v.store.bucket)}
+	}
+
+	// Determine the maximum number of keys needed based on limit and offset
+	excludeDeleted := !query.IncludeDeleted && v.hasDeleted()
+	maxKeys := 0
+	if query.Limit > 0 && !excludeDeleted {
+		maxKeys = query.Offset + query.Limit
+	}
+
+	var keyPrefix string
+	if len(query.KeyPrefix) > 0 {
+		var err error
+		keyPrefix, err = v.store.encodeKeyPrefix(query.KeyPrefix)
+		if err != nil {
+			return nil, err
 		}
+	}
+
+	// Ordered results leave out soft-deleted records while ordering, so they can stop at the end of the page
+	wantedKeys := 0
+	if query.Limit > 0 {
+		wantedKeys = query.Offset + query.Limit
+	}
 
-		// Skip offset and take only limit number of keys
-		start := query.Offset
-		if start > len(candidateKeys) {
-			start = len(candidateKeys)
+	// Cursors continue past a record in the order of the query, walking the order backwards before it
+	order := v.store.resultOrder(query)
+	var after *sortEntry
+	if cursor := query.After + query.Before; cursor != "" {
+		entry, ok := v.store.decodeCursor(cursor, order)
+		if !ok {
+			return nil, InvalidQueryError{Field: "After", Value: cursor, Reason: "is not a cursor of a query with this order"}
 		}
-		end := len(candidateKeys)
-		if query.Limit > 0 && start+query.Limit < end {
-			end = start + query.Limit
+		after = &entry
+		if query.Before != "" {
+			order = order.reversed()
 		}
-		keysToFetch := candidateKeys[start:end]
+	}
 
-		// Retrieve the actual data for the selected keys
-		bucket := tx.Bucket(s.bucket)
-		if bucket == nil {
-			return BucketNotFoundError{Bucket: string(s.bucket)}
+	// Gather keys that potentially match the query conditions
+	var candidateKeys []string
+	if len(query.OrderBy) > 0 {
+		// Selected records are ordered by their sort keys before the page is taken
+		selectQuery := *query
+		selectQuery.OrderBy = nil
+		selectQuery.Limit = 0
+		selectQuery.Offset = 0
+		selectQuery.After = ""
+		selectQuery.Before = ""
+		keys, err := v.getQueryKeys(&selectQuery)
+		if err != nil {
+			return nil, err
 		}
-		decoder := msgpack.GetDecoder()
-		defer msgpack.PutDecoder(decoder)
-		for _, key := range keysToFetch {
-			data := bucket.Get([]byte(key))
-			if data == nil {
-				continue
-			}
-			var item T
-			decoder.Reset(bytes.NewReader(data))
-			err := decoder.Decode(&item)
-			if err != nil {
-				continue
-			}
-			results = append(results, item)
+		candidateKeys = v.sortKeysBy(keys, order, wantedKeys, after)
+		excludeDeleted = false
+	} else if query.Index != "" && (query.hasFilter() || after != nil) {
+		// Filtered results are ordered by the index before the page is taken
+		candidateKeys = v.getOrderedFilterKeys(query, order, after, keyPrefix, excludeDeleted, wantedKeys)
+		excludeDeleted = false
+	} else if after != nil {
+		candidateKeys = v.getKeysPastKey(query, *after, order.keyDesc, keyPrefix, excludeDeleted, wantedKeys)
+		excludeDeleted = false
+	} else if query.hasFilter() || query.Index != "" {
+		if keyPrefix != "" {
+			// The key prefix is applied afterwards, so the limit cannot be pushed down
+			maxKeys = 0
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		if query.hasFilter() {
+			candidateKeys = v.getFilterKeys(query, maxKeys)
+		} else {
+			// When no conditions but sorting is required, use the index directly
+			candidateKeys = v.getKeysFromIndex(query.Index, query.Sort, maxKeys)
+		}
+		if keyPrefix != "" {
+			candidateKeys = filterKeyPrefix(candidateKeys, keyPrefix)
+		}
+	} else if keyPrefix != "" {
+		// Only scan the range of keys sharing the prefix
+		candidateKeys = v.getKeysWithPrefix(keyPrefix, maxKeys)
+	} else {
+		// Fallback to scanning all keys when no optimizations apply
+		candidateKeys = v.getAllKeys(maxKeys)
 	}
-
-	// Apply sorting if the index wasn't used for ordering
-	if query.Index != "" && len(query.Conditions) > 0 {
-		s.sortResults(results, query.Index, query.Sort)
+	if excludeDeleted {
+		candidateKeys = v.filterDeleted(candidateKeys)
 	}
 
-	return results, nil
+	// Skip offset and take only limit number of keys
+	start := query.Offset
+	if start > len(candidateKeys) {
+		start = len(candidateKeys)
+	}
+	end := len(candidateKeys)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+	}
+	keys := candidateKeys[start:end]
+	if query.Before != "" {
+		// Keys before the cursor were gathered walking backwards
+		for left, right := 0, len(keys)-1; left < right; left, right = left+1, right-1 {
+			keys[left], keys[right] = keys[right], keys[left]
+		}
+	}
+	return keys, nil
 }
@@ -0,0 +1,312 @@
+package nnut
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// indexValueWidth returns the width of the encoded index values of a field type, zero for variable width strings,
+// or false when the type cannot be indexed
+func indexValueWidth(fieldType reflect.Type) (int, bool) {
+	if fieldType == timeType {
+		return 12, true
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		return 0, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return 8, true
+	case reflect.Bool:
+		return 1, true
+	}
+	return 0, false
+}
+
+// indexedType returns the type of the values an index holds for a field type, with slices holding an entry per
+// element and maps an entry per key, or false when the type cannot be indexed
+func indexedType(fieldType reflect.Type) (reflect.Type, bool, bool) {
+	valueType, isMultiValue := fieldType, false
+	switch fieldType.Kind() {
+	case reflect.Slice:
+		valueType, isMultiValue = fieldType.Elem(), true
+	case reflect.Map:
+		valueType, isMultiValue = fieldType.Key(), true
+	}
+	_, ok := indexValueWidth(valueType)
+	return valueType, isMultiValue, ok
+}
+
+// indexLayout describes how the values of an index are laid out in its entries
+type indexLayout struct {
+	width int // width of single field values with a fixed width, zero otherwise
+	parts int // number of fields making up the value
+}
+
+// indexLayout returns the layout of the values of an index
+func (s *Store[T]) indexLayout(index string) indexLayout {
+	fieldIndexes := s.indexFields[index]
+	if len(fieldIndexes) > 1 {
+		return indexLayout{parts: len(fieldIndexes)}
+	} else if len(fieldIndexes) == 0 {
+		return indexLayout{parts: 1}
+	}
+	valueType, _, _ := indexedType(s.fields[fieldIndexes[0]].fieldType)
+	width, _ := indexValueWidth(valueType)
+	return indexLayout{width: width, parts: 1}
+}
+
+// split splits an index entry into its value and primary key
+func (layout indexLayout) split(entry []byte) ([]byte, []byte, bool) {
+	if layout.width > 0 {
+		if len(entry) <= layout.width || entry[layout.width] != 0x00 {
+			return nil, nil, false
+		}
+		return entry[:layout.width], entry[layout.width+1:], true
+	}
+	// Variable width values cannot contain the separator, composite values escape it within their fields
+	position := -1
+	for part := 0; part < layout.parts; part++ {
+		next := bytes.IndexByte(entry[position+1:], 0x00)
+		if next == -1 {
+			return nil, nil, false
+		}
+		position += next + 1
+	}
+	return entry[:position], entry[position+1:], true
+}
+
+// encodeIndexValue converts a field value into its index form, whose byte order matches the natural order of the values
+func encodeIndexValue(value reflect.Value) string {
+	if value.Type() == timeType {
+		return encodeTime(value.Interface().(time.Time))
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return encodeSigned(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return encodeUnsigned(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return encodeFloat(value.Float())
+	case reflect.Bool:
+		if value.Bool() {
+			return "\x01"
+		}
+		return "\x00"
+	}
+	return ""
+}
+
+// encodeConditionValue converts the value of a condition into the index form of the condition field,
+// reporting false when the value cannot be compared through the index
+func (s *Store[T]) encodeConditionValue(condition Condition) (string, bool) {
+	index, ok := s.conditionIndex(condition)
+	if !ok {
+		return "", false
+	}
+	// Multi-value indexes hold elements, which are only looked up for containment
+	switch condition.Operator {
+	case Contains:
+		if !s.multiValue[index] {
+			return "", false
+		}
+	case HasPrefix:
+		// String values sort by their bytes, so values sharing a prefix are adjacent
+		if valueType, _, _ := indexedType(s.fields[s.indexFields[index][0]].fieldType); s.multiValue[index] || valueType.Kind() != reflect.String {
+			return "", false
+		}
+	case ContainsAny, ContainsAll, In, NotEquals, NotIn, Like, Regexp:
+		return "", false
+	default:
+		if s.multiValue[index] {
+			return "", false
+		}
+	}
+	return s.encodeFieldValue(s.indexFields[index][0], condition.Value)
+}
+
+// encodeFieldValue converts a value into the index form of a field, or of its elements for multi-value fields,
+// reporting false when the value cannot be compared with the field through an index
+func (s *Store[T]) encodeFieldValue(fieldIndex int, fieldValue interface{}) (string, bool) {
+	if fieldValue == nil {
+		return "", false
+	}
+	fieldType, _, _ := indexedType(s.fields[fieldIndex].fieldType)
+	value := reflect.ValueOf(fieldValue)
+
+	if fieldType == timeType {
+		if instant, ok := fieldValue.(time.Time); ok {
+			return encodeTime(instant), true
+		}
+		return "", false
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		if value.Kind() == reflect.String {
+			return s.collate(fieldIndex, value.String()), true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if signed, ok := signedValue(value); ok {
+			return encodeSigned(signed), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if unsigned, ok := unsignedValue(value); ok {
+			return encodeUnsigned(unsigned), true
+		}
+	case reflect.Float32, reflect.Float64:
+		if float, ok := floatValue(value); ok {
+			return encodeFloat(float), true
+		}
+	case reflect.Bool:
+		if value.Kind() == reflect.Bool {
+			return encodeIndexValue(value), true
+		}
+	}
+	return "", false
+}
+
+// encodeSigned encodes a signed integer so that byte order matches numeric order
+func encodeSigned(value int64) string {
+	// Flipping the sign bit sorts negative numbers before positive ones
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(value)^(1<<63))
+	return string(encoded)
+}
+
+// encodeUnsigned encodes an unsigned integer so that byte order matches numeric order
+func encodeUnsigned(value uint64) string {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, value)
+	return string(encoded)
+}
+
+// encodeFloat encodes a floating point number so that byte order matches numeric order
+func encodeFloat(value float64) string {
+	if value == 0 {
+		// Negative zero equals positive zero
+		value = 0
+	}
+	bits := math.Float64bits(value)
+	if bits&(1<<63) != 0 {
+		// Negative numbers sort in reverse of their bits
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, bits)
+	return string(encoded)
+}
+
+// encodeTime encodes a time so that byte order matches chronological order
+func encodeTime(value time.Time) string {
+	// Seconds with a flipped sign bit followed by nanoseconds keep the full time range in order
+	encoded := make([]byte, 12)
+	binary.BigEndian.PutUint64(encoded, uint64(value.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(encoded[8:], uint32(value.Nanosecond()))
+	return string(encoded)
+}
+
+// decodeIndexValue converts the value of an index entry back into a value of the index fields, or a slice of
+// values for composite indexes. Collated strings come back in their normalised form.
+func (s *Store[T]) decodeIndexValue(index string, encoded []byte) interface{} {
+	fieldIndexes := s.indexFields[index]
+	if len(fieldIndexes) == 1 {
+		valueType, _, _ := indexedType(s.fields[fieldIndexes[0]].fieldType)
+		return decodeIndexValue(valueType, string(encoded))
+	}
+	encodedParts := strings.Split(string(encoded), "\x00")
+	values := make([]interface{}, len(fieldIndexes))
+	for position, fieldIndex := range fieldIndexes {
+		encodedPart := ""
+		if position < len(encodedParts) {
+			encodedPart = unescapeKeyPart(encodedParts[position])
+		}
+		values[position] = decodeIndexValue(s.fields[fieldIndex].fieldType, encodedPart)
+	}
+	return values
+}
+
+// decodeIndexValue reverses encodeIndexValue for a value of the type
+func decodeIndexValue(valueType reflect.Type, encoded string) interface{} {
+	value := reflect.New(valueType).Elem()
+	if valueType == timeType {
+		if len(encoded) == 12 {
+			seconds := int64(binary.BigEndian.Uint64([]byte(encoded)) ^ (1 << 63))
+			nanoseconds := int64(binary.BigEndian.Uint32([]byte(encoded[8:])))
+			value.Set(reflect.ValueOf(time.Unix(seconds, nanoseconds).UTC()))
+		}
+		return value.Interface()
+	}
+	switch valueType.Kind() {
+	case reflect.String:
+		value.SetString(encoded)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if len(encoded) == 8 {
+			value.SetInt(int64(binary.BigEndian.Uint64([]byte(encoded)) ^ (1 << 63)))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if len(encoded) == 8 {
+			value.SetUint(binary.BigEndian.Uint64([]byte(encoded)))
+		}
+	case reflect.Float32, reflect.Float64:
+		if len(encoded) == 8 {
+			bits := binary.BigEndian.Uint64([]byte(encoded))
+			if bits&(1<<63) != 0 {
+				bits &^= 1 << 63
+			} else {
+				bits = ^bits
+			}
+			value.SetFloat(math.Float64frombits(bits))
+		}
+	case reflect.Bool:
+		value.SetBool(encoded == "\x01")
+	}
+	return value.Interface()
+}
+
+// signedValue converts an integer value to int64, reporting false for other values and for values out of range
+func signedValue(value reflect.Value) (int64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() <= math.MaxInt64 {
+			return int64(value.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+// unsignedValue converts an integer value to uint64, reporting false for other values and for negative values
+func unsignedValue(value reflect.Value) (uint64, bool) {
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() >= 0 {
+			return uint64(value.Int()), true
+		}
+	}
+	return 0, false
+}
+
+// floatValue converts a numeric value to float64, reporting false for other values
+func floatValue(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(value.Uint()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package nnut
+
+import (
+	"reflect"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// collation normalises the string values of an index field, so that text that is equal under the collation shares
+// an entry and sorts together
+type collation struct {
+	fold bool   // compare case-insensitively through Unicode case folding, given as nnut:"index:email,fold"
+	form string // Unicode normalisation form, nfc or nfkc, given as nnut:"index:email,collate=nfkc"
+}
+
+// collationForms holds the supported Unicode normalisation forms by name
+var collationForms = map[string]norm.Form{
+	"nfc":  norm.NFC,
+	"nfkc": norm.NFKC,
+}
+
+// apply normalises a string value
+func (c collation) apply(value string) string {
+	form, normalize := collationForms[c.form]
+	if normalize {
+		value = form.String(value)
+	}
+	if c.fold {
+		value = cases.Fold().String(value)
+		// Folding can leave text that is no longer normalised
+		if normalize {
+			value = form.String(value)
+		}
+	}
+	return value
+}
+
+// applyValue normalises a string, or the strings held by a slice or as the keys of a map, leaving other values as they are
+func (c collation) applyValue(value interface{}) interface{} {
+	collection := reflect.ValueOf(value)
+	var elements []reflect.Value
+	switch collection.Kind() {
+	case reflect.String:
+		return c.apply(collection.String())
+	case reflect.Slice, reflect.Array:
+		for index := 0; index < collection.Len(); index++ {
+			elements = append(elements, collection.Index(index))
+		}
+	case reflect.Map:
+		elements = collection.MapKeys()
+	default:
+		return value
+	}
+	normalized := make([]interface{}, len(elements))
+	for index, element := range elements {
+		if element.Kind() == reflect.String {
+			normalized[index] = c.apply(element.String())
+		} else {
+			normalized[index] = element.Interface()
+		}
+	}
+	return normalized
+}
+
+// collate normalises the index form of a value of a field according to the collation of the field
+func (s *Store[T]) collate(fieldIndex int, value string) string {
+	if collation, ok := s.collations[fieldIndex]; ok {
+		return collation.apply(value)
+	}
+	return value
+}
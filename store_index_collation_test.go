@@ -0,0 +1,143 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type TestPerson struct {
+	ID    string   `nnut:"key"`
+	Email string   `nnut:"index:Email,fold,unique"`
+	Name  string   `nnut:"index:Name,fold,collate=nfkc"`
+	Tags  []string `nnut:"index:Tags,fold"`
+}
+
+func TestCollatedIndexes(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestPerson](db, "people")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	people := []TestPerson{
+		{ID: "a", Email: "Ron@Example.com", Name: "ｒｏｎ", Tags: []string{"Friend"}},
+		{ID: "b", Email: "ana@example.com", Name: "Ana", Tags: []string{"WORK", "friend"}},
+		{ID: "c", Email: "ZOE@example.com", Name: "Ébé", Tags: []string{"family"}},
+	}
+	err = store.PutBatch(context.Background(), people[:1])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), people[1:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	ids := func(results []TestPerson) string {
+		var ids string
+		for _, result := range results {
+			ids += result.ID
+		}
+		return ids
+	}
+
+	for _, test := range []struct {
+		condition Condition
+		expected  string
+	}{
+		{Condition{Field: "Email", Value: "ron@example.COM"}, "a"},
+		{Condition{Field: "Email", Value: "b", Operator: GreaterThan}, "ac"},
+		{Condition{Field: "Name", Value: "RON"}, "a"},
+		{Condition{Field: "Name", Value: "ébé"}, "c"},
+		{Condition{Field: "Tags", Value: "FRIEND", Operator: Contains}, "ab"},
+		{Condition{Field: "Tags", Value: []string{"Work", "Family"}, Operator: ContainsAny}, "bc"},
+	} {
+		results, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{test.condition}})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if ids(results) != test.expected {
+			t.Fatalf("Expected %s for %+v, got %s", test.expected, test.condition, ids(results))
+		}
+		// Matching records directly gives the same answer as the index
+		for _, person := range people {
+			if store.matchesCondition(person, test.condition) != strings.Contains(test.expected, person.ID) {
+				t.Fatalf("Expected matching %s against %+v to agree with the index", person.ID, test.condition)
+			}
+		}
+	}
+
+	// The index sorts case-insensitively
+	results, err := store.GetQuery(context.Background(), &Query{Index: "Email", Sort: Ascending})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "bac" {
+		t.Fatalf("Expected bac, got %s", ids(results))
+	}
+
+	// Sorting matches follows the collation as well
+	results, err = store.GetQuery(context.Background(), &Query{Index: "Email", Sort: Ascending, Conditions: []Condition{{Field: "Tags", Value: []string{"friend", "family"}, Operator: ContainsAny}}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "bac" {
+		t.Fatalf("Expected bac, got %s", ids(results))
+	}
+
+	// Unique values are compared after folding
+	err = store.Put(context.Background(), TestPerson{ID: "d", Email: "RON@example.com"})
+	if _, ok := err.(UniqueConstraintError); !ok {
+		t.Fatalf("Expected UniqueConstraintError, got %v", err)
+	}
+	person, err := store.GetByUnique(context.Background(), "Email", "zoe@EXAMPLE.com")
+	if err != nil {
+		t.Fatalf("Failed to get by unique: %v", err)
+	}
+	if person.ID != "c" {
+		t.Fatalf("Expected c, got %s", person.ID)
+	}
+}
+
+func TestCollationOptions(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type unknownForm struct {
+		ID   string `nnut:"key"`
+		Name string `nnut:"index:name,collate=nfx"`
+	}
+	_, err = NewStore[unknownForm](db, "unknown")
+	if _, ok := err.(IndexOptionError); !ok {
+		t.Fatalf("Expected IndexOptionError, got %v", err)
+	}
+
+	type foldedNumber struct {
+		ID  string `nnut:"key"`
+		Age int    `nnut:"index:age,fold"`
+	}
+	_, err = NewStore[foldedNumber](db, "numbers")
+	if _, ok := err.(IndexOptionError); !ok {
+		t.Fatalf("Expected IndexOptionError, got %v", err)
+	}
+}
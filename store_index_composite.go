@@ -0,0 +1,153 @@
+package nnut
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// compositeMatch describes the conditions of a query answered by a composite index
+type compositeMatch struct {
+	index      string
+	prefix     string     // escaped values of the leading fields compared for equality, each followed by a separator
+	bound      *Condition // range condition on the field following the prefix, nil when there is none
+	boundValue string     // escaped index form of the bound value
+	used       []bool     // conditions answered by the index, by position
+	count      int        // number of conditions answered by the index
+}
+
+// matchComposite picks the composite index answering the most conditions, through equality on its leading
+// fields followed by at most one range on the next field. A single condition is only answered by a composite
+// index when no single field index answers it.
+func (s *Store[T]) matchComposite(conditions []Condition) (compositeMatch, bool) {
+	var names []string
+	for name, fieldIndexes := range s.indexFields {
+		if len(fieldIndexes) > 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var best compositeMatch
+	for _, name := range names {
+		match := compositeMatch{index: name, used: make([]bool, len(conditions))}
+		for _, fieldIndex := range s.indexFields[name] {
+			position, encoded := s.findCompositeCondition(conditions, match.used, fieldIndex, true)
+			if position >= 0 {
+				match.prefix += escapeKeyPart(encoded) + "\x00"
+				match.used[position] = true
+				match.count++
+				continue
+			}
+			position, encoded = s.findCompositeCondition(conditions, match.used, fieldIndex, false)
+			if position >= 0 {
+				match.bound = &conditions[position]
+				match.boundValue = escapeKeyPart(encoded)
+				match.used[position] = true
+				match.count++
+			}
+			break
+		}
+		if match.count > best.count {
+			best = match
+		}
+	}
+
+	if best.count == 0 {
+		return best, false
+	}
+	if best.count == 1 {
+		for position, used := range best.used {
+			if _, indexed := s.encodeConditionValue(conditions[position]); used && indexed {
+				return best, false
+			}
+		}
+	}
+	return best, true
+}
+
+// findCompositeCondition returns the position of an unused condition on the field that compares for equality,
+// or for a range when equality is false, together with the index form of its value, or -1 when there is none
+func (s *Store[T]) findCompositeCondition(conditions []Condition, used []bool, fieldIndex int, equality bool) (int, string) {
+	for position, condition := range conditions {
+		if used[position] || (condition.Operator == Equals) != equality || !equality && !isRangeOperator(condition.Operator) {
+			continue
+		}
+		if conditionField, ok := s.resolveField(condition.Field); !ok || conditionField != fieldIndex {
+			continue
+		}
+		if encoded, ok := s.encodeFieldValue(fieldIndex, condition.Value); ok {
+			return position, encoded
+		}
+	}
+	return -1, ""
+}
+
+// encodeIndexLookup converts a value of an index into its index form, taking a slice of values in index order
+// for composite indexes, and reports false when the value does not match the index fields
+func (s *Store[T]) encodeIndexLookup(index string, value interface{}) (string, bool) {
+	fieldIndexes := s.indexFields[index]
+	if len(fieldIndexes) == 1 {
+		return s.encodeFieldValue(fieldIndexes[0], value)
+	}
+	values, ok := value.([]interface{})
+	if !ok || len(values) != len(fieldIndexes) {
+		return "", false
+	}
+	parts := make([]string, len(values))
+	for position, fieldIndex := range fieldIndexes {
+		encoded, ok := s.encodeFieldValue(fieldIndex, values[position])
+		if !ok {
+			return "", false
+		}
+		parts[position] = escapeKeyPart(encoded)
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// isRangeOperator reports whether an operator compares against one side of a value
+func isRangeOperator(operator Operator) bool {
+	return operator == GreaterThan || operator == GreaterThanOrEqual || operator == LessThan || operator == LessThanOrEqual
+}
+
+// getKeysForComposite returns the keys matching the conditions answered by a composite index, sorted
+func (v *StoreView[T]) getKeysForComposite(match compositeMatch, maxKeys int) []string {
+	var keys []string
+	indexBucketName := string(v.store.bucket) + "_index_" + match.index
+	cursor := v.snapshot.cursor([]byte(indexBucketName))
+	layout := v.store.indexLayout(match.index)
+	prefix := []byte(match.prefix)
+	bound := []byte(match.boundValue)
+
+	// Lower bounds on the range field start the walk at the bound
+	start := prefix
+	if match.bound != nil && (match.bound.Operator == GreaterThan || match.bound.Operator == GreaterThanOrEqual) {
+		start = append(append([]byte(nil), prefix...), bound...)
+	}
+	for keyBytes, _ := cursor.Seek(start); keyBytes != nil && bytes.HasPrefix(keyBytes, prefix); keyBytes, _ = cursor.Next() {
+		if match.bound != nil {
+			rest := keyBytes[len(prefix):]
+			end := bytes.IndexByte(rest, 0x00)
+			if end == -1 {
+				continue
+			}
+			comparison := bytes.Compare(rest[:end], bound)
+			if match.bound.Operator == GreaterThan && comparison <= 0 ||
+				match.bound.Operator == GreaterThanOrEqual && comparison < 0 {
+				continue
+			}
+			if match.bound.Operator == LessThan && comparison >= 0 ||
+				match.bound.Operator == LessThanOrEqual && comparison > 0 {
+				break
+			}
+		}
+		if maxKeys > 0 && len(keys) >= maxKeys {
+			break
+		}
+		if _, key, ok := layout.split(keyBytes); ok {
+			keys = append(keys, string(key))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
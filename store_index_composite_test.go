@@ -0,0 +1,259 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type TestTask struct {
+	ID        string    `nnut:"key"`
+	Status    string    `nnut:"index:status_created:1"`
+	CreatedAt time.Time `nnut:"index:status_created:2"`
+	Priority  int       `nnut:"index:Priority"`
+}
+
+func TestCompositeIndex(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestTask](db, "tasks")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tasks := []TestTask{
+		{ID: "a", Status: "open", CreatedAt: base.Add(3 * time.Hour), Priority: 1},
+		{ID: "b", Status: "done", CreatedAt: base, Priority: 2},
+		{ID: "c", Status: "open", CreatedAt: base, Priority: 2},
+		{ID: "d", Status: "open\x00x", CreatedAt: base, Priority: 1},
+		{ID: "e", Status: "open", CreatedAt: base.Add(time.Hour), Priority: 1},
+		{ID: "f", Status: "done", CreatedAt: base.Add(2 * time.Hour), Priority: 1},
+	}
+	err = store.PutBatch(context.Background(), tasks[:3])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), tasks[3:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	ids := func(results []TestTask) string {
+		var ids string
+		for _, result := range results {
+			ids += result.ID
+		}
+		return ids
+	}
+
+	// Sorting by the composite index orders by each field in turn
+	results, err := store.GetQuery(context.Background(), &Query{Index: "status_created", Sort: Ascending})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "bfcead" {
+		t.Fatalf("Expected bfcead, got %s", ids(results))
+	}
+
+	// Equality on the leading field with a range on the next field is answered by the index
+	for _, test := range []struct {
+		conditions []Condition
+		count      int
+		expected   string
+	}{
+		{[]Condition{{Field: "Status", Value: "open"}}, 1, "ace"},
+		{[]Condition{{Field: "Status", Value: "open"}, {Field: "CreatedAt", Value: base}}, 2, "c"},
+		{[]Condition{{Field: "Status", Value: "open"}, {Field: "CreatedAt", Value: base, Operator: GreaterThan}}, 2, "ae"},
+		{[]Condition{{Field: "Status", Value: "open"}, {Field: "CreatedAt", Value: base.Add(time.Hour), Operator: GreaterThanOrEqual}}, 2, "ae"},
+		{[]Condition{{Field: "Status", Value: "open"}, {Field: "CreatedAt", Value: base.Add(time.Hour), Operator: LessThan}}, 2, "c"},
+		{[]Condition{{Field: "CreatedAt", Value: base.Add(time.Hour), Operator: LessThanOrEqual}, {Field: "Status", Value: "open"}}, 2, "ce"},
+		{[]Condition{{Field: "Status", Value: "open"}, {Field: "CreatedAt", Value: base, Operator: GreaterThan}, {Field: "Priority", Value: 1}}, 2, "ae"},
+		{[]Condition{{Field: "Status", Value: "done"}, {Field: "Priority", Value: 2}}, 1, "b"},
+	} {
+		match, matched := store.matchComposite(test.conditions)
+		if !matched || match.count != test.count {
+			t.Fatalf("Expected the composite index to answer %d conditions of %+v, got %d", test.count, test.conditions, match.count)
+		}
+
+		results, err := store.GetQuery(context.Background(), &Query{Conditions: test.conditions})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if ids(results) != test.expected {
+			t.Fatalf("Expected %s for %+v, got %s", test.expected, test.conditions, ids(results))
+		}
+		count, err := store.CountQuery(context.Background(), &Query{Conditions: test.conditions})
+		if err != nil {
+			t.Fatalf("Failed to count: %v", err)
+		}
+		if count != len(test.expected) {
+			t.Fatalf("Expected count %d for %+v, got %d", len(test.expected), test.conditions, count)
+		}
+	}
+
+	// A range on the leading field alone walks the composite index from its start
+	results, err = store.GetQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Status", Value: "open", Operator: GreaterThan}}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "d" {
+		t.Fatalf("Expected d, got %s", ids(results))
+	}
+
+	// Updates move records within the index
+	tasks[1].Status = "open"
+	err = store.Put(context.Background(), tasks[1])
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	results, err = store.GetQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Status", Value: "open"}, {Field: "CreatedAt", Value: base}}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "bc" {
+		t.Fatalf("Expected bc, got %s", ids(results))
+	}
+}
+
+func TestCompositeIndexOrder(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type unnumbered struct {
+		ID       string `nnut:"key"`
+		TenantID string `nnut:"index:tenant_email"`
+		Email    string `nnut:"index:tenant_email"`
+	}
+	_, err = NewStore[unnumbered](db, "unnumbered")
+	if _, ok := err.(IndexFieldOrderError); !ok {
+		t.Fatalf("Expected IndexFieldOrderError, got %v", err)
+	}
+
+	type duplicate struct {
+		ID       string `nnut:"key"`
+		TenantID string `nnut:"index:tenant_email:1"`
+		Email    string `nnut:"index:tenant_email:1"`
+	}
+	_, err = NewStore[duplicate](db, "duplicate")
+	if _, ok := err.(IndexFieldOrderError); !ok {
+		t.Fatalf("Expected IndexFieldOrderError, got %v", err)
+	}
+
+	// Unique composite indexes reject a second record with the same values
+	type account struct {
+		ID       string `nnut:"key"`
+		TenantID string `nnut:"index:tenant_email:1,unique"`
+		Email    string `nnut:"index:tenant_email:2"`
+	}
+	store, err := NewStore[account](db, "accounts")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.PutBatch(context.Background(), []account{
+		{ID: "1", TenantID: "x", Email: "ron@example.com"},
+		{ID: "2", TenantID: "y", Email: "ron@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Put(context.Background(), account{ID: "3", TenantID: "x", Email: "ron@example.com"})
+	if _, ok := err.(UniqueConstraintError); !ok {
+		t.Fatalf("Expected UniqueConstraintError, got %v", err)
+	}
+	result, err := store.GetByUnique(context.Background(), "tenant_email", []interface{}{"y", "ron@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to get by unique: %v", err)
+	}
+	if result.ID != "2" {
+		t.Fatalf("Expected account 2, got %s", result.ID)
+	}
+}
+
+func TestCompositeIndexSharedField(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	// A field of a composite index can have an index of its own as well
+	type task struct {
+		ID        string    `nnut:"key"`
+		Status    string    `nnut:"index:status;index:status_created:1"`
+		CreatedAt time.Time `nnut:"index:status_created:2"`
+	}
+	store, err := NewStore[task](db, "tasks")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = store.PutBatch(context.Background(), []task{
+		{ID: "a", Status: "open", CreatedAt: base.Add(time.Hour)},
+		{ID: "b", Status: "done", CreatedAt: base},
+		{ID: "c", Status: "open", CreatedAt: base},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Put(context.Background(), task{ID: "b", Status: "open", CreatedAt: base.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	db.Flush()
+
+	// Both indexes follow the update
+	for index, expected := range map[string]string{"status": "abc", "status_created": "cab"} {
+		results, err := store.GetQuery(context.Background(), &Query{Index: index})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		var ids string
+		for _, result := range results {
+			ids += result.ID
+		}
+		if ids != expected {
+			t.Fatalf("Expected %s ordered by %s, got %s", expected, index, ids)
+		}
+	}
+	count, err := store.CountQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Status", Value: "done"}}})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Expected no done tasks, got %d", count)
+	}
+
+	// Values are normalised once per field, so its indexes cannot normalise differently
+	type conflicting struct {
+		ID    string `nnut:"key"`
+		Email string `nnut:"index:email,fold;index:email_lower,collate=nfkc"`
+	}
+	_, err = NewStore[conflicting](db, "conflicting")
+	if _, ok := err.(IndexOptionError); !ok {
+		t.Fatalf("Expected IndexOptionError, got %v", err)
+	}
+}
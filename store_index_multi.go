@@ -0,0 +1,152 @@
+package nnut
+
+import (
+	"reflect"
+	"sort"
+)
+
+// encodeIndexValues converts the elements of a slice, or the keys of a map, of a field into their distinct index
+// forms, sorted
+func (s *Store[T]) encodeIndexValues(fieldIndex int, value reflect.Value) []string {
+	var elements []reflect.Value
+	if value.Kind() == reflect.Map {
+		elements = value.MapKeys()
+	} else {
+		for index := 0; index < value.Len(); index++ {
+			elements = append(elements, value.Index(index))
+		}
+	}
+	seen := make(map[string]bool, len(elements))
+	var values []string
+	for _, element := range elements {
+		encoded := s.collate(fieldIndex, encodeIndexValue(element))
+		if !seen[encoded] {
+			seen[encoded] = true
+			values = append(values, encoded)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// encodeConditionElements converts the values of a ContainsAny or ContainsAll condition on a multi-value index, or
+// of an In condition on another index, into their index form, reporting false when the condition cannot be
+// answered through the index
+func (s *Store[T]) encodeConditionElements(condition Condition) ([]string, bool) {
+	index, ok := s.conditionIndex(condition)
+	if !ok {
+		return nil, false
+	}
+	switch condition.Operator {
+	case ContainsAny, ContainsAll:
+		if !s.multiValue[index] {
+			return nil, false
+		}
+	case In:
+		if s.multiValue[index] {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	elements, ok := conditionElements(condition.Value)
+	if !ok || len(elements) == 0 {
+		return nil, false
+	}
+	fieldIndex := s.indexFields[index][0]
+	values := make([]string, len(elements))
+	for position, element := range elements {
+		encoded, ok := s.encodeFieldValue(fieldIndex, element)
+		if !ok {
+			return nil, false
+		}
+		values[position] = encoded
+	}
+	return values, true
+}
+
+// indexedCondition reports whether a condition can be answered through an index
+func (s *Store[T]) indexedCondition(condition Condition) bool {
+	if _, ok := s.encodeConditionValue(condition); ok {
+		return true
+	}
+	_, ok := s.encodeConditionElements(condition)
+	return ok
+}
+
+// getKeysForElements returns the keys of the records holding any of the values of a ContainsAny or In condition
+// in an index, or all of them for ContainsAll, sorted
+func (v *StoreView[T]) getKeysForElements(condition Condition, maxKeys int) []string {
+	values, ok := v.store.encodeConditionElements(condition)
+	if !ok {
+		return nil
+	}
+	index, _ := v.store.conditionIndex(condition)
+	var keys []string
+	if condition.Operator == ContainsAll {
+		for position, value := range values {
+			if position == 0 {
+				keys = v.indexKeys(index, value)
+			} else {
+				keys = intersectSlices(keys, v.indexKeys(index, value))
+			}
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, value := range values {
+			for _, key := range v.indexKeys(index, value) {
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+		sort.Strings(keys)
+	}
+	if maxKeys > 0 && len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+	}
+	return keys
+}
+
+// conditionElements returns the values of a slice or array condition value, reporting false for other values
+func conditionElements(value interface{}) ([]interface{}, bool) {
+	list := reflect.ValueOf(value)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return nil, false
+	}
+	elements := make([]interface{}, list.Len())
+	for index := range elements {
+		elements[index] = list.Index(index).Interface()
+	}
+	return elements, true
+}
+
+// containsElements reports whether a slice, or the keys of a map, holds any of the values, or all of them when all
+// is true
+func containsElements(collection reflect.Value, values []interface{}, all bool) bool {
+	var elements []reflect.Value
+	switch collection.Kind() {
+	case reflect.Map:
+		elements = collection.MapKeys()
+	case reflect.Slice, reflect.Array:
+		for index := 0; index < collection.Len(); index++ {
+			elements = append(elements, collection.Index(index))
+		}
+	default:
+		return false
+	}
+	for _, value := range values {
+		found := false
+		for _, element := range elements {
+			if equalValues(element.Interface(), value) {
+				found = true
+				break
+			}
+		}
+		if found != all {
+			return found
+		}
+	}
+	return all
+}
@@ -0,0 +1,186 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type TestArticle struct {
+	ID       string         `nnut:"key"`
+	Tags     []string       `nnut:"index:Tags"`
+	Ratings  map[int]string `nnut:"index:Ratings"`
+	Keywords []string
+}
+
+func TestMultiValueIndex(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestArticle](db, "articles")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	articles := []TestArticle{
+		{ID: "a", Tags: []string{"go", "db"}, Ratings: map[int]string{5: "great"}, Keywords: []string{"bolt"}},
+		{ID: "b", Tags: []string{"go", "go"}, Ratings: map[int]string{3: "fine", 4: "good"}},
+		{ID: "c", Tags: []string{"rust"}, Keywords: []string{"bolt", "wal"}},
+		{ID: "d", Tags: []string{"db", "rust", "go"}, Ratings: map[int]string{4: "good"}},
+	}
+	err = store.PutBatch(context.Background(), articles[:2])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), articles[2:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	ids := func(results []TestArticle) string {
+		var ids string
+		for _, result := range results {
+			ids += result.ID
+		}
+		return ids
+	}
+
+	// Containment is answered through an entry per element, or map key
+	for _, test := range []struct {
+		condition Condition
+		expected  string
+	}{
+		{Condition{Field: "Tags", Value: "go", Operator: Contains}, "abd"},
+		{Condition{Field: "Tags", Value: "none", Operator: Contains}, ""},
+		{Condition{Field: "Tags", Value: []string{"rust", "db"}, Operator: ContainsAny}, "acd"},
+		{Condition{Field: "Tags", Value: []interface{}{"go", "db"}, Operator: ContainsAll}, "ad"},
+		{Condition{Field: "Ratings", Value: 4, Operator: Contains}, "bd"},
+		{Condition{Field: "Ratings", Value: []int{3, 5}, Operator: ContainsAny}, "ab"},
+	} {
+		var indexed []string
+		err := db.view(context.Background(), store.bucket, nil, func(snapshot *Snapshot) error {
+			indexed = store.In(snapshot).getKeysForCondition(test.condition, 0)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to view: %v", err)
+		}
+		if len(indexed) != len(test.expected) {
+			t.Fatalf("Expected %d index matches for %+v, got %d", len(test.expected), test.condition, len(indexed))
+		}
+
+		results, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{test.condition}})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if ids(results) != test.expected {
+			t.Fatalf("Expected %s for %+v, got %s", test.expected, test.condition, ids(results))
+		}
+		count, err := store.CountQuery(context.Background(), &Query{Conditions: []Condition{test.condition}})
+		if err != nil {
+			t.Fatalf("Failed to count: %v", err)
+		}
+		if count != len(test.expected) {
+			t.Fatalf("Expected count %d for %+v, got %d", len(test.expected), test.condition, count)
+		}
+	}
+
+	// Fields without an index are matched by scanning
+	results, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{
+		{Field: "Keywords", Value: "bolt", Operator: Contains},
+		{Field: "Tags", Value: "go", Operator: Contains},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "a" {
+		t.Fatalf("Expected a, got %s", ids(results))
+	}
+
+	// Updates only change the entries of added and removed elements
+	updated := articles[3]
+	updated.Tags = []string{"go", "db", "sql"}
+	operations := store.indexOperations(store.extractIndexValues(articles[3]), store.extractIndexValues(updated))
+	if len(operations) != 1 || operations[0].IndexName != "Tags" || operations[0].OldValue != "rust" || operations[0].NewValue != "sql" {
+		t.Fatalf("Expected rust to be replaced by sql, got %+v", operations)
+	}
+	err = store.Put(context.Background(), updated)
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	results, err = store.GetQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Tags", Value: "rust", Operator: Contains}}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "c" {
+		t.Fatalf("Expected c, got %s", ids(results))
+	}
+
+	// Deleting removes every entry of the record
+	err = store.Delete(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	db.Flush()
+	err = db.view(context.Background(), store.bucket, nil, func(snapshot *Snapshot) error {
+		if count := snapshot.count([]byte("articles_index_Tags")); count != 6 {
+			t.Errorf("Expected 6 tag entries, got %d", count)
+		}
+		if count := snapshot.count([]byte("articles_index_Ratings")); count != 2 {
+			t.Errorf("Expected 2 rating entries, got %d", count)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to view: %v", err)
+	}
+}
+
+func TestMultiValueIndexQueryValidation(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestArticle](db, "articles")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	for _, query := range []*Query{
+		{Index: "Tags"},
+		{Conditions: []Condition{{Field: "Tags", Value: "go", Operator: ContainsAny}}},
+		{Conditions: []Condition{{Field: "Tags", Value: []string{}, Operator: ContainsAll}}},
+		{Conditions: []Condition{{Field: "Tags", Value: []interface{}{nil}, Operator: ContainsAny}}},
+	} {
+		_, err := store.GetQuery(context.Background(), query)
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Fatalf("Expected InvalidQueryError for %+v, got %v", query, err)
+		}
+	}
+
+	// Multi-value fields cannot be part of a composite index
+	type invalidComposite struct {
+		ID     string   `nnut:"key"`
+		Status string   `nnut:"index:status_tags:1"`
+		Tags   []string `nnut:"index:status_tags:2"`
+	}
+	_, err = NewStore[invalidComposite](db, "invalid")
+	if _, ok := err.(IndexFieldTypeError); !ok {
+		t.Fatalf("Expected IndexFieldTypeError, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type TestMeasurement struct {
+	ID        string    `nnut:"key"`
+	Offset    int       `nnut:"index:Offset"`
+	Count     uint16    `nnut:"index:Count"`
+	Value     float64   `nnut:"index:Value"`
+	Valid     bool      `nnut:"index:Valid"`
+	CreatedAt time.Time `nnut:"index:CreatedAt"`
+}
+
+func TestOrderedIndexes(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestMeasurement](db, "measurements")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	measurements := []TestMeasurement{
+		{ID: "a", Offset: -300, Count: 1, Value: -2.5, Valid: true, CreatedAt: base.Add(-time.Hour)},
+		{ID: "b", Offset: -2, Count: 300, Value: -0.25, Valid: false, CreatedAt: base},
+		{ID: "c", Offset: 0, Count: 2, Value: 0, Valid: true, CreatedAt: base.Add(time.Second)},
+		{ID: "d", Offset: 256, Count: 0, Value: 10, Valid: false, CreatedAt: base.Add(time.Nanosecond)},
+		{ID: "e", Offset: 1 << 40, Count: 65535, Value: 1e10, Valid: true, CreatedAt: base.AddDate(1, 0, 0)},
+	}
+	err = store.PutBatch(context.Background(), measurements[:3])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), measurements[3:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	ids := func(results []TestMeasurement) string {
+		var ids string
+		for _, result := range results {
+			ids += result.ID
+		}
+		return ids
+	}
+
+	// Each index is walked in the natural order of its values
+	for index, expected := range map[string]string{
+		"Offset":    "abcde",
+		"Count":     "dacbe",
+		"Value":     "abcde",
+		"Valid":     "bdace",
+		"CreatedAt": "abdce",
+	} {
+		results, err := store.GetQuery(context.Background(), &Query{Index: index, Sort: Ascending})
+		if err != nil {
+			t.Fatalf("Failed to query %s: %v", index, err)
+		}
+		if ids(results) != expected {
+			t.Fatalf("Expected %s order %s, got %s", index, expected, ids(results))
+		}
+	}
+
+	// Range operators are resolved through the index
+	for _, test := range []struct {
+		condition Condition
+		expected  string
+	}{
+		{Condition{Field: "Offset", Value: -2, Operator: GreaterThan}, "cde"},
+		{Condition{Field: "Offset", Value: int64(0), Operator: LessThanOrEqual}, "abc"},
+		{Condition{Field: "Count", Value: 2, Operator: GreaterThanOrEqual}, "bce"},
+		{Condition{Field: "Count", Value: uint8(0), Operator: Equals}, "d"},
+		{Condition{Field: "Value", Value: 0, Operator: LessThan}, "ab"},
+		{Condition{Field: "Value", Value: -0.25, Operator: GreaterThanOrEqual}, "bcde"},
+		{Condition{Field: "Valid", Value: true}, "ace"},
+		{Condition{Field: "CreatedAt", Value: base, Operator: GreaterThan}, "cde"},
+	} {
+		var indexed []string
+		err := db.view(context.Background(), store.bucket, nil, func(snapshot *Snapshot) error {
+			indexed = store.In(snapshot).getKeysForCondition(test.condition, 0)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to view: %v", err)
+		}
+		if len(indexed) != len(test.expected) {
+			t.Fatalf("Expected %d index matches for %+v, got %d", len(test.expected), test.condition, len(indexed))
+		}
+
+		results, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{test.condition}})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if ids(results) != test.expected {
+			t.Fatalf("Expected %s for %+v, got %s", test.expected, test.condition, ids(results))
+		}
+		count, err := store.CountQuery(context.Background(), &Query{Conditions: []Condition{test.condition}})
+		if err != nil {
+			t.Fatalf("Failed to count: %v", err)
+		}
+		if count != len(test.expected) {
+			t.Fatalf("Expected count %d for %+v, got %d", len(test.expected), test.condition, count)
+		}
+	}
+
+	// Updates move records within the index
+	measurements[0].Offset = 1000
+	err = store.Put(context.Background(), measurements[0])
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	results, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Offset", Value: 0, Operator: GreaterThan}}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "ade" {
+		t.Fatalf("Expected ade, got %s", ids(results))
+	}
+}
+
+func TestUnsupportedIndexType(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type invalidIndex struct {
+		ID     string     `nnut:"key"`
+		Groups [][]string `nnut:"index:groups"`
+	}
+	_, err = NewStore[invalidIndex](db, "invalid")
+	if _, ok := err.(IndexFieldTypeError); !ok {
+		t.Fatalf("Expected IndexFieldTypeError, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package nnut
+
+import (
+	"reflect"
+	"sort"
+)
+
+// IndexInfo describes an index of a store
+type IndexInfo struct {
+	Name       string         // name given in the index tag
+	Fields     []string       // paths of the indexed fields, in index order
+	Types      []reflect.Type // types of the indexed fields, in index order
+	Unique     bool           // each value may be held by one record only
+	MultiValue bool           // the index holds an entry per element of a slice or key of a map
+	Fold       bool           // string values compare case-insensitively
+	Collate    string         // Unicode normalisation form of string values, empty when they are not normalised
+	FullText   bool           // the index holds the terms of a text field for full-text search
+}
+
+// Indexes lists the indexes of the store, sorted by name
+func (s *Store[T]) Indexes() []IndexInfo {
+	indexes := make([]IndexInfo, 0, len(s.indexFields))
+	for name, fieldIndexes := range s.indexFields {
+		index := IndexInfo{Name: name, Unique: s.unique[name], MultiValue: s.multiValue[name]}
+		for _, fieldIndex := range fieldIndexes {
+			index.Fields = append(index.Fields, s.fields[fieldIndex].name)
+			index.Types = append(index.Types, s.fields[fieldIndex].fieldType)
+			if collation, ok := s.collations[fieldIndex]; ok {
+				index.Fold = index.Fold || collation.fold
+				if collation.form != "" {
+					index.Collate = collation.form
+				}
+			}
+		}
+		indexes = append(indexes, index)
+	}
+	for name, fieldIndex := range s.fullText {
+		field := s.fields[fieldIndex]
+		indexes = append(indexes, IndexInfo{Name: name, Fields: []string{field.name}, Types: []reflect.Type{field.fieldType}, FullText: true})
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].Name < indexes[j].Name
+	})
+	return indexes
+}
+
+// resolveIndex maps a name used in a query, either an index name or the path of a field with an index of its own,
+// to the name of the index, reporting false when neither exists. Index names take precedence.
+func (s *Store[T]) resolveIndex(name string) (string, bool) {
+	if _, ok := s.indexFields[name]; ok {
+		return name, true
+	}
+	if fieldIndex, ok := s.fieldMap[name]; ok {
+		index, ok := s.indexOfField[fieldIndex]
+		return index, ok
+	}
+	return "", false
+}
+
+// resolveField maps a name used in a condition, either a field path or the name of an index on a single field,
+// to the field, reporting false when neither exists. Field paths take precedence.
+func (s *Store[T]) resolveField(name string) (int, bool) {
+	if fieldIndex, ok := s.fieldMap[name]; ok {
+		return fieldIndex, true
+	}
+	if fieldIndexes, ok := s.indexFields[name]; ok && len(fieldIndexes) == 1 {
+		return fieldIndexes[0], true
+	}
+	return 0, false
+}
+
+// conditionIndex returns the name of the index on the field of a condition, reporting false when it has none
+func (s *Store[T]) conditionIndex(condition Condition) (string, bool) {
+	fieldIndex, ok := s.resolveField(condition.Field)
+	if !ok {
+		return "", false
+	}
+	index, ok := s.indexOfField[fieldIndex]
+	return index, ok
+}
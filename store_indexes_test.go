@@ -0,0 +1,108 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexResolution(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.PutBatch(context.Background(), []TestUser{
+		{UUID: "1", Name: "Ron", Email: "ron@example.com", Age: 30},
+		{UUID: "2", Name: "Ana", Email: "ana@example.com", Age: 25},
+		{UUID: "3", Name: "Ron", Email: "ron@example.org", Age: 40},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// Conditions on a field use the index of the field, whether named by field or by index
+	for _, field := range []string{"Name", "name"} {
+		condition := Condition{Field: field, Value: "Ron"}
+		var indexed []string
+		err := db.view(context.Background(), store.bucket, nil, func(snapshot *Snapshot) error {
+			indexed = store.In(snapshot).getKeysForCondition(condition, 0)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to view: %v", err)
+		}
+		if len(indexed) != 2 {
+			t.Fatalf("Expected 2 index matches for %s, got %d", field, len(indexed))
+		}
+		count, err := store.CountQuery(context.Background(), &Query{Conditions: []Condition{condition}})
+		if err != nil {
+			t.Fatalf("Failed to count: %v", err)
+		}
+		if count != 2 {
+			t.Fatalf("Expected count 2 for %s, got %d", field, count)
+		}
+	}
+
+	// Sorting accepts the field name of an index as well
+	for _, index := range []string{"Age", "age"} {
+		results, err := store.GetQuery(context.Background(), &Query{Index: index, Sort: Descending})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(results) != 3 || results[0].UUID != "3" || results[2].UUID != "2" {
+			t.Fatalf("Expected users ordered by age for %s, got %+v", index, results)
+		}
+	}
+
+	_, err = store.GetQuery(context.Background(), &Query{Index: "UUID"})
+	if _, ok := err.(InvalidQueryError); !ok {
+		t.Fatalf("Expected InvalidQueryError for a field without an index, got %v", err)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type account struct {
+		ID        string    `nnut:"key"`
+		Email     string    `nnut:"index:email,unique"`
+		Status    string    `nnut:"index:status_created:1"`
+		CreatedAt time.Time `nnut:"index:status_created:2"`
+		Roles     []string  `nnut:"index:roles"`
+	}
+	store, err := NewStore[account](db, "accounts")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	stringType := reflect.TypeOf("")
+	expected := []IndexInfo{
+		{Name: "email", Fields: []string{"Email"}, Types: []reflect.Type{stringType}, Unique: true},
+		{Name: "roles", Fields: []string{"Roles"}, Types: []reflect.Type{reflect.TypeOf([]string{})}, MultiValue: true},
+		{Name: "status_created", Fields: []string{"Status", "CreatedAt"}, Types: []reflect.Type{stringType, timeType}},
+	}
+	if indexes := store.Indexes(); !reflect.DeepEqual(indexes, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, indexes)
+	}
+}
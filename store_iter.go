@@ -0,0 +1,305 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// iterChunkSize is the number of records read per read transaction while iterating
+const iterChunkSize = 256
+
+// Iter returns an iterator over the records matching the query, matching the iter.Seq2 signature so it can be
+// ranged over on Go 1.23 and later, or called with a yield function on earlier versions.
+// Records are decoded in chunks, each within a short-lived read transaction, and yielded outside of it, so the
+// records of a long scan reflect writes made while iterating. Returning false from yield stops the iteration.
+// An error is yielded with a zero record and ends the iteration.
+//
+//	for user, err := range userStore.Iter(ctx, query) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+func (s *Store[T]) Iter(ctx context.Context, query *Query) func(yield func(T, error) bool) {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if err := s.validateQuery(query); err != nil {
+			yield(zero, err)
+			return
+		}
+
+		// Offset and limit are applied to the records as they are yielded
+		skip := query.Offset
+		remaining := query.Limit
+		emit := func(chunk []T) (bool, error) {
+			for _, item := range chunk {
+				if skip > 0 {
+					skip--
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return false, ctx.Err()
+				default:
+				}
+				if !yield(item, nil) {
+					return false, nil
+				}
+				if query.Limit > 0 {
+					remaining--
+					if remaining == 0 {
+						return false, nil
+					}
+				}
+			}
+			return true, nil
+		}
+
+		var err error
+		if len(query.OrderBy) > 0 || query.Before != "" {
+			err = s.iterSorted(ctx, query, emit)
+		} else if query.Index != "" {
+			err = s.iterIndex(ctx, query, emit)
+		} else {
+			err = s.iterKeys(ctx, query, emit)
+		}
+		if err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// iterExpression returns the filter of a query prepared for matching, or nil when it has none
+func (s *Store[T]) iterExpression(query *Query) Expression {
+	if !query.hasFilter() {
+		return nil
+	}
+	return prepareExpression(s.queryExpression(query))
+}
+
+// iterAfter returns the position the After cursor of a query points at, or nil when it has none
+func (s *Store[T]) iterAfter(query *Query) (*sortEntry, error) {
+	if query.After == "" {
+		return nil, nil
+	}
+	entry, ok := s.decodeCursor(query.After, s.resultOrder(query))
+	if !ok {
+		return nil, InvalidQueryError{Field: "After", Value: query.After, Reason: "is not a cursor of a query with this order"}
+	}
+	return &entry, nil
+}
+
+// iterKeys yields the records of a query without index by walking the primary keys in chunks, matching the
+// records against the filter of the query as they pass
+func (s *Store[T]) iterKeys(ctx context.Context, query *Query, emit func([]T) (bool, error)) error {
+	var prefix []byte
+	if len(query.KeyPrefix) > 0 {
+		encoded, err := s.encodeKeyPrefix(query.KeyPrefix)
+		if err != nil {
+			return err
+		}
+		prefix = []byte(encoded)
+	}
+	after, err := s.iterAfter(query)
+	if err != nil {
+		return err
+	}
+	cursor := ""
+	if after != nil {
+		cursor = base64.RawURLEncoding.EncodeToString([]byte(after.key))
+	}
+	expression := s.iterExpression(query)
+
+	for first := true; first || cursor != ""; first = false {
+		var chunk []T
+		err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+			if first && !snapshot.exists(s.bucket) {
+				return BucketNotFoundError{Bucket: string(s.bucket)}
+			}
+			var err error
+			chunk, cursor, err = s.In(snapshot).scan(ctx, prefix, prefixEnd(prefix), &RangeOptions{
+				Limit:          iterChunkSize,
+				Cursor:         cursor,
+				IncludeDeleted: query.IncludeDeleted,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if expression != nil {
+			matches := chunk[:0]
+			for _, item := range chunk {
+				if s.matchesExpression(item, expression) {
+					matches = append(matches, item)
+				}
+			}
+			chunk = matches
+		}
+		if more, err := emit(chunk); !more || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iterIndex yields the records of a query in the order of its index by walking the index in chunks, resuming
+// past the last entry walked and matching the records against the filter of the query as they pass
+func (s *Store[T]) iterIndex(ctx context.Context, query *Query, emit func([]T) (bool, error)) error {
+	index, _ := s.resolveIndex(query.Index)
+	descending := query.Sort == Descending
+	var keyPrefix string
+	if len(query.KeyPrefix) > 0 {
+		var err error
+		keyPrefix, err = s.encodeKeyPrefix(query.KeyPrefix)
+		if err != nil {
+			return err
+		}
+	}
+	after, err := s.iterAfter(query)
+	if err != nil {
+		return err
+	}
+	var seek []byte
+	if after != nil {
+		var ok bool
+		if seek, ok = s.indexSeek(index, *after); !ok && descending {
+			// Records without a value sort before every entry of the index
+			return nil
+		}
+	}
+	expression := s.iterExpression(query)
+	layout := s.indexLayout(index)
+	indexBucket := []byte(string(s.bucket) + "_index_" + index)
+	tombstoneBucket := tombstoneBucketName(s.bucket)
+
+	for first, done := true, false; !done; first = false {
+		var chunk []T
+		var sorted bool
+		err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+			view := s.In(snapshot)
+			if first {
+				if !snapshot.exists(s.bucket) {
+					return BucketNotFoundError{Bucket: string(s.bucket)}
+				}
+				// Records without a value for the index are missing from it, yet filtered queries sort them first
+				if expression != nil && view.countKeysFromIndex(index) != view.countAllKeys() {
+					sorted = true
+					return nil
+				}
+			}
+
+			decoder := msgpack.GetDecoder()
+			defer msgpack.PutDecoder(decoder)
+			cursor := snapshot.cursor(indexBucket)
+			walked := 0
+			keyBytes, _ := seekPast(cursor, seek, descending)
+			for ; keyBytes != nil && walked < iterChunkSize; keyBytes, _ = stepCursor(cursor, descending) {
+				walked++
+				seek = append(seek[:0], keyBytes...)
+				_, entryKey, ok := layout.split(keyBytes)
+				if !ok || !strings.HasPrefix(string(entryKey), keyPrefix) {
+					continue
+				}
+				if !query.IncludeDeleted && snapshot.get(tombstoneBucket, entryKey) != nil {
+					continue
+				}
+				data := snapshot.get(s.bucket, entryKey)
+				if data == nil {
+					continue
+				}
+				var item T
+				decoder.Reset(bytes.NewReader(data))
+				if err := decoder.Decode(&item); err != nil {
+					return WrappedError{Operation: "decode", Bucket: string(s.bucket), Key: s.formatKey(string(entryKey)), Err: err}
+				}
+				if expression != nil && !s.matchesExpression(item, expression) {
+					continue
+				}
+				chunk = append(chunk, item)
+			}
+			done = keyBytes == nil
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if sorted {
+			return s.iterSorted(ctx, query, emit)
+		}
+
+		if more, err := emit(chunk); !more || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iterSorted yields the records of a query that are only ordered once all of them are known, by OrderBy or
+// walking back from a Before cursor, resolving the matching keys first. The records are read in chunks and
+// matched against the query again, so records that changed since the keys were resolved are left out.
+func (s *Store[T]) iterSorted(ctx context.Context, query *Query, emit func([]T) (bool, error)) error {
+	resolveQuery := *query
+	resolveQuery.Offset = 0
+	if query.Limit > 0 {
+		resolveQuery.Limit = query.Offset + query.Limit
+	}
+	var keys []string
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		keys, err = s.In(snapshot).getQueryKeys(&resolveQuery)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	expression := s.iterExpression(query)
+	tombstoneBucket := tombstoneBucketName(s.bucket)
+	for start := 0; start < len(keys); start += iterChunkSize {
+		end := start + iterChunkSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		// Records removed or changed to no longer match since the keys were resolved are skipped
+		var chunk []T
+		err := s.database.view(ctx, s.bucket, keys[start:end], func(snapshot *Snapshot) error {
+			decoder := msgpack.GetDecoder()
+			defer msgpack.PutDecoder(decoder)
+			for _, key := range keys[start:end] {
+				data := snapshot.get(s.bucket, []byte(key))
+				if data == nil {
+					continue
+				}
+				if !query.IncludeDeleted && snapshot.get(tombstoneBucket, []byte(key)) != nil {
+					continue
+				}
+				var item T
+				decoder.Reset(bytes.NewReader(data))
+				err := decoder.Decode(&item)
+				if err != nil {
+					return WrappedError{Operation: "decode", Bucket: string(s.bucket), Key: s.formatKey(key), Err: err}
+				}
+				if expression != nil && !s.matchesExpression(item, expression) {
+					continue
+				}
+				chunk = append(chunk, item)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if more, err := emit(chunk); !more || err != nil {
+			return err
+		}
+	}
+	return nil
+}
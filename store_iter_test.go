@@ -0,0 +1,228 @@
+package nnut
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIter(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Span several chunks with flushed and buffered records
+	var users []TestUser
+	for i := 0; i < iterChunkSize*2+10; i++ {
+		name := "even"
+		if i%2 == 1 {
+			name = "odd"
+		}
+		users = append(users, TestUser{UUID: fmt.Sprintf("%04d", i), Name: name, Age: i})
+	}
+	err = store.PutBatch(context.Background(), users[:iterChunkSize])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), users[iterChunkSize:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	collect := func(query *Query) ([]TestUser, error) {
+		var results []TestUser
+		var iterErr error
+		store.Iter(context.Background(), query)(func(user TestUser, err error) bool {
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			results = append(results, user)
+			return true
+		})
+		return results, iterErr
+	}
+
+	for _, query := range []*Query{
+		{},
+		{Offset: 5, Limit: iterChunkSize + 3},
+		{Conditions: []Condition{{Field: "Name", Value: "odd"}}},
+		{Index: "name", Sort: Descending, Limit: 20},
+		{Index: "age", Conditions: []Condition{{Field: "Name", Value: "odd"}}, Offset: 3, Limit: iterChunkSize},
+		{Index: "age", Sort: Descending, Where: Or(Condition{Field: "Age", Operator: LessThan, Value: 10}, Condition{Field: "Age", Operator: GreaterThan, Value: 500})},
+		{Where: Not(Condition{Field: "Name", Value: "odd"}), Limit: 30},
+		{OrderBy: []SortKey{{Field: "Name"}, {Field: "Age", Desc: true}}, Limit: iterChunkSize + 1},
+	} {
+		expected, err := store.GetQuery(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		results, err := collect(query)
+		if err != nil {
+			t.Fatalf("Failed to iterate: %v", err)
+		}
+		if len(results) != len(expected) {
+			t.Fatalf("Expected %d results for %+v, got %d", len(expected), query, len(results))
+		}
+		for index := range expected {
+			if results[index] != expected[index] {
+				t.Fatalf("Expected %+v at %d for %+v, got %+v", expected[index], index, query, results[index])
+			}
+		}
+	}
+
+	// Stopping early ends the iteration
+	count := 0
+	store.Iter(context.Background(), &Query{})(func(user TestUser, err error) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Fatalf("Expected 3 calls, got %d", count)
+	}
+
+	// Writing while iterating does not block
+	count = 0
+	store.Iter(context.Background(), &Query{Limit: 2})(func(user TestUser, err error) bool {
+		if err != nil {
+			t.Fatalf("Failed to iterate: %v", err)
+		}
+		user.Name = "changed"
+		if err := store.Put(context.Background(), user); err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+		db.Flush()
+		count++
+		return true
+	})
+	if count != 2 {
+		t.Fatalf("Expected 2 calls, got %d", count)
+	}
+}
+
+func TestIterContext(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.PutBatch(context.Background(), []TestUser{{UUID: "1"}, {UUID: "2"}, {UUID: "3"}})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// Cancelling mid-scan yields the context error and stops
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var seen []string
+	var iterErr error
+	store.Iter(ctx, &Query{})(func(user TestUser, err error) bool {
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		seen = append(seen, user.UUID)
+		cancel()
+		return true
+	})
+	if len(seen) != 1 || !errors.Is(iterErr, context.Canceled) {
+		t.Fatalf("Expected one record and context.Canceled, got %v and %v", seen, iterErr)
+	}
+
+	// Invalid queries yield their error
+	iterErr = nil
+	store.Iter(context.Background(), &Query{Limit: -1})(func(user TestUser, err error) bool {
+		iterErr = err
+		return false
+	})
+	if _, ok := iterErr.(InvalidQueryError); !ok {
+		t.Fatalf("Expected InvalidQueryError, got %v", iterErr)
+	}
+}
+
+func TestIterConcurrentWrite(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	var users []TestUser
+	for i := 0; i < iterChunkSize*3; i++ {
+		users = append(users, TestUser{UUID: fmt.Sprintf("%04d", i), Name: "match", Age: i})
+	}
+	err = store.PutBatch(context.Background(), users)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+
+	// A record changed to no longer match before its chunk is read is not yielded
+	last := users[len(users)-1]
+	for _, query := range []*Query{
+		{Conditions: []Condition{{Field: "Name", Value: "match"}}},
+		{Index: "age", Conditions: []Condition{{Field: "Name", Value: "match"}}},
+		{Conditions: []Condition{{Field: "Name", Value: "match"}}, OrderBy: []SortKey{{Field: "Age"}}},
+	} {
+		err = store.Put(context.Background(), last)
+		if err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+		db.Flush()
+
+		count := 0
+		store.Iter(context.Background(), query)(func(user TestUser, err error) bool {
+			if err != nil {
+				t.Fatalf("Failed to iterate: %v", err)
+			}
+			if count == 0 {
+				changed := last
+				changed.Name = "changed"
+				if err := store.Put(context.Background(), changed); err != nil {
+					t.Fatalf("Failed to put: %v", err)
+				}
+				db.Flush()
+			}
+			if user.UUID == last.UUID {
+				t.Fatalf("Expected %s to be left out for %+v, got %+v", last.UUID, query, user)
+			}
+			count++
+			return true
+		})
+		if count != len(users)-1 {
+			t.Fatalf("Expected %d records for %+v, got %d", len(users)-1, query, count)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package nnut
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// keyKind identifies how a primary key type is encoded
+type keyKind int
+
+const (
+	stringKey   keyKind = iota
+	signedKey           // any signed integer type
+	unsignedKey         // any unsigned integer type
+	uuidKey             // any [16]byte type, such as a UUID
+	timeKey             // time.Time
+)
+
+// keyPart is a field that makes up the primary key
+type keyPart struct {
+	field     int // index of the field in the struct
+	order     int // position given in the nnut:"key:N" tag, zero for a single key field
+	kind      keyKind
+	fieldType reflect.Type
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// keyKindOf returns the key kind for a field type, or false when the type cannot be used as a key
+func keyKindOf(fieldType reflect.Type) (keyKind, bool) {
+	if fieldType == timeType {
+		return timeKey, true
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		return stringKey, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return signedKey, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return unsignedKey, true
+	case reflect.Array:
+		if fieldType.Len() == 16 && fieldType.Elem().Kind() == reflect.Uint8 {
+			return uuidKey, true
+		}
+	}
+	return 0, false
+}
+
+// escapeKeyPart rewrites the bytes 0x00 and 0x01 so parts can be joined with 0x00 without changing their order
+func escapeKeyPart(part string) string {
+	if !strings.ContainsAny(part, "\x00\x01") {
+		return part
+	}
+	var escaped strings.Builder
+	for index := 0; index < len(part); index++ {
+		switch part[index] {
+		case 0x00:
+			escaped.WriteString("\x01\x01")
+		case 0x01:
+			escaped.WriteString("\x01\x02")
+		default:
+			escaped.WriteByte(part[index])
+		}
+	}
+	return escaped.String()
+}
+
+// unescapeKeyPart reverses escapeKeyPart
+func unescapeKeyPart(escaped string) string {
+	if !strings.Contains(escaped, "\x01") {
+		return escaped
+	}
+	var part strings.Builder
+	for index := 0; index < len(escaped); index++ {
+		if escaped[index] == 0x01 && index+1 < len(escaped) {
+			index++
+			part.WriteByte(escaped[index] - 1)
+			continue
+		}
+		part.WriteByte(escaped[index])
+	}
+	return part.String()
+}
+
+// keyOf returns the encoded primary key of a record
+func (s *Store[T]) keyOf(value T) (string, error) {
+	structValue := reflect.ValueOf(value)
+	parts := make([]reflect.Value, len(s.keyParts))
+	for index, part := range s.keyParts {
+		parts[index] = structValue.Field(part.field)
+	}
+	return s.encodeKeyParts(parts)
+}
+
+// encodeKey converts a typed key into its stored form.
+// Composite keys are given as a slice holding a value for every key part.
+func (s *Store[T]) encodeKey(key interface{}) (string, error) {
+	if key == nil {
+		return "", s.keyTypeError(0, "nil")
+	}
+	if len(s.keyParts) == 1 {
+		return s.encodeKeyPart(0, reflect.ValueOf(key))
+	}
+	parts, err := s.keyPartValues(key)
+	if err != nil {
+		return "", err
+	}
+	if len(parts) != len(s.keyParts) {
+		return "", InvalidKeyError{Key: fmt.Sprint(key)}
+	}
+	return s.encodeKeyParts(parts)
+}
+
+// encodeKeyPrefix converts the leading parts of a composite key into the prefix shared by all matching stored keys
+func (s *Store[T]) encodeKeyPrefix(parts []interface{}) (string, error) {
+	if len(parts) >= len(s.keyParts) {
+		return "", InvalidKeyError{Key: fmt.Sprint(parts)}
+	}
+	var prefix strings.Builder
+	for index, part := range parts {
+		if part == nil {
+			return "", s.keyTypeError(index, "nil")
+		}
+		encoded, err := s.encodeKeyPart(index, reflect.ValueOf(part))
+		if err != nil {
+			return "", err
+		}
+		prefix.WriteString(escapeKeyPart(encoded))
+		prefix.WriteByte(0x00)
+	}
+	return prefix.String(), nil
+}
+
+// encodeKeyParts joins the encoded parts of a key, escaping them when the key is composite
+func (s *Store[T]) encodeKeyParts(parts []reflect.Value) (string, error) {
+	if len(s.keyParts) == 1 {
+		return s.encodeKeyPart(0, parts[0])
+	}
+	encodedParts := make([]string, len(parts))
+	for index, part := range parts {
+		encoded, err := s.encodeKeyPart(index, part)
+		if err != nil {
+			return "", err
+		}
+		encodedParts[index] = escapeKeyPart(encoded)
+	}
+	return strings.Join(encodedParts, "\x00"), nil
+}
+
+// keyPartValues returns the parts of a composite key given as a slice
+func (s *Store[T]) keyPartValues(key interface{}) ([]reflect.Value, error) {
+	keyValue := reflect.ValueOf(key)
+	if keyValue.Kind() != reflect.Slice {
+		return nil, s.keyTypeError(0, keyValue.Type().String())
+	}
+	parts := make([]reflect.Value, keyValue.Len())
+	for index := range parts {
+		part := keyValue.Index(index)
+		if part.Kind() == reflect.Interface {
+			if part.IsNil() {
+				return nil, s.keyTypeError(index, "nil")
+			}
+			part = part.Elem()
+		}
+		parts[index] = part
+	}
+	return parts, nil
+}
+
+// encodeKeyPart converts a key part into its stored form, whose byte order matches the natural order of the values.
+// Integer values of any integer type are accepted as long as the value fits the key field.
+func (s *Store[T]) encodeKeyPart(index int, value reflect.Value) (string, error) {
+	part := s.keyParts[index]
+	valueKind, ok := keyKindOf(value.Type())
+	if !ok {
+		return "", s.keyTypeError(index, value.Type().String())
+	}
+
+	switch part.kind {
+	case stringKey:
+		if valueKind != stringKey {
+			break
+		}
+		key := value.String()
+		if err := validateKey(key); err != nil {
+			return "", err
+		}
+		return key, nil
+	case signedKey:
+		var signed int64
+		if valueKind == signedKey {
+			signed = value.Int()
+		} else if valueKind == unsignedKey && value.Uint() <= math.MaxInt64 {
+			signed = int64(value.Uint())
+		} else {
+			break
+		}
+		if reflect.Zero(part.fieldType).OverflowInt(signed) {
+			return "", InvalidKeyError{Key: fmt.Sprint(signed)}
+		}
+		return encodeSigned(signed), nil
+	case unsignedKey:
+		var unsigned uint64
+		if valueKind == unsignedKey {
+			unsigned = value.Uint()
+		} else if valueKind == signedKey && value.Int() >= 0 {
+			unsigned = uint64(value.Int())
+		} else if valueKind == signedKey {
+			return "", InvalidKeyError{Key: fmt.Sprint(value.Int())}
+		} else {
+			break
+		}
+		if reflect.Zero(part.fieldType).OverflowUint(unsigned) {
+			return "", InvalidKeyError{Key: fmt.Sprint(unsigned)}
+		}
+		return encodeUnsigned(unsigned), nil
+	case uuidKey:
+		if valueKind != uuidKey {
+			break
+		}
+		encoded := make([]byte, 16)
+		reflect.Copy(reflect.ValueOf(encoded), value)
+		return string(encoded), nil
+	case timeKey:
+		if valueKind != timeKey {
+			break
+		}
+		return encodeTime(value.Interface().(time.Time)), nil
+	}
+	return "", s.keyTypeError(index, value.Type().String())
+}
+
+// decodeKey converts a stored key back into a value of the key field type, or a slice of values for composite keys
+func (s *Store[T]) decodeKey(encoded string) interface{} {
+	if len(s.keyParts) == 1 {
+		return s.decodeKeyPart(0, encoded)
+	}
+	encodedParts := strings.Split(encoded, "\x00")
+	parts := make([]interface{}, len(s.keyParts))
+	for index := range s.keyParts {
+		encodedPart := ""
+		if index < len(encodedParts) {
+			encodedPart = unescapeKeyPart(encodedParts[index])
+		}
+		parts[index] = s.decodeKeyPart(index, encodedPart)
+	}
+	return parts
+}
+
+// decodeKeyPart converts a stored key part back into a value of its field type
+func (s *Store[T]) decodeKeyPart(index int, encoded string) interface{} {
+	part := s.keyParts[index]
+	key := reflect.New(part.fieldType).Elem()
+	switch part.kind {
+	case stringKey:
+		key.SetString(encoded)
+	case signedKey:
+		if len(encoded) == 8 {
+			key.SetInt(int64(binary.BigEndian.Uint64([]byte(encoded)) ^ (1 << 63)))
+		}
+	case unsignedKey:
+		if len(encoded) == 8 {
+			key.SetUint(binary.BigEndian.Uint64([]byte(encoded)))
+		}
+	case uuidKey:
+		reflect.Copy(key, reflect.ValueOf([]byte(encoded)))
+	case timeKey:
+		if len(encoded) == 12 {
+			seconds := int64(binary.BigEndian.Uint64([]byte(encoded)) ^ (1 << 63))
+			nanoseconds := int64(binary.BigEndian.Uint32([]byte(encoded[8:])))
+			key.Set(reflect.ValueOf(time.Unix(seconds, nanoseconds).UTC()))
+		}
+	}
+	return key.Interface()
+}
+
+// keyPartEscaper escapes the slashes joining the parts of a formatted composite key within the parts
+var keyPartEscaper = strings.NewReplacer(`\`, `\\`, "/", `\/`)
+
+// formatKey returns a readable form of a stored key, joining composite key parts with a slash.
+// Slashes and backslashes within the parts are escaped with a backslash, so no two keys format alike.
+func (s *Store[T]) formatKey(encoded string) string {
+	var parts []interface{}
+	if len(s.keyParts) == 1 {
+		parts = []interface{}{s.decodeKey(encoded)}
+	} else {
+		parts = s.decodeKey(encoded).([]interface{})
+	}
+	formatted := make([]string, len(parts))
+	for index, part := range parts {
+		if s.keyParts[index].kind == uuidKey {
+			value := reflect.ValueOf(part)
+			raw := make([]byte, 16)
+			reflect.Copy(reflect.ValueOf(raw), value)
+			formatted[index] = hex.EncodeToString(raw)
+			continue
+		}
+		formatted[index] = fmt.Sprint(part)
+		if len(parts) > 1 {
+			formatted[index] = keyPartEscaper.Replace(formatted[index])
+		}
+	}
+	return strings.Join(formatted, "/")
+}
+
+// encodeKeys converts a slice of typed keys into their stored form
+func (s *Store[T]) encodeKeys(keys interface{}) ([]string, error) {
+	if keys == nil {
+		return nil, nil
+	}
+	keysValue := reflect.ValueOf(keys)
+	if keysValue.Kind() != reflect.Slice {
+		return nil, s.keyTypeError(0, keysValue.Type().String())
+	}
+	encodedKeys := make([]string, keysValue.Len())
+	for index := range encodedKeys {
+		encoded, err := s.encodeKey(keysValue.Index(index).Interface())
+		if err != nil {
+			return nil, err
+		}
+		encodedKeys[index] = encoded
+	}
+	return encodedKeys, nil
+}
+
+// keyedResults maps batch results from their stored keys to the readable form of the keys
+func (s *Store[T]) keyedResults(results map[string]T) map[string]T {
+	if results == nil {
+		return nil
+	}
+	keyed := make(map[string]T, len(results))
+	for encodedKey, item := range results {
+		keyed[s.formatKey(encodedKey)] = item
+	}
+	return keyed
+}
+
+// orderedResults puts batch results in the order of their stored keys, with nil for keys not found
+func orderedResults[T any](results map[string]T, encodedKeys []string) []*T {
+	if results == nil {
+		return nil
+	}
+	ordered := make([]*T, len(encodedKeys))
+	for index, encodedKey := range encodedKeys {
+		if item, exists := results[encodedKey]; exists {
+			ordered[index] = &item
+		}
+	}
+	return ordered
+}
+
+// keyTypeError reports a key part that does not match the type of its key field
+func (s *Store[T]) keyTypeError(index int, actual string) error {
+	var zeroValue T
+	if index >= len(s.keyParts) {
+		index = len(s.keyParts) - 1
+	}
+	part := s.keyParts[index]
+	return InvalidFieldTypeError{
+		FieldName: reflect.TypeOf(zeroValue).Field(part.field).Name,
+		Expected:  part.fieldType.String(),
+		Actual:    actual,
+	}
+}
@@ -0,0 +1,232 @@
+package nnut
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	autoKeySequence = "seq"   // increasing integers from the bucket sequence
+	autoKeyULID     = "ulid"  // monotonic ULIDs
+	autoKeyUUID7    = "uuid7" // monotonic version 7 UUIDs
+)
+
+// validateAutoKey checks that a key of the given kind can be generated with the strategy
+func validateAutoKey(strategy string, kind keyKind) error {
+	switch strategy {
+	case autoKeySequence:
+		if kind != signedKey && kind != unsignedKey {
+			return errors.New("requires an integer key field")
+		}
+	case autoKeyULID, autoKeyUUID7:
+		if kind != stringKey && kind != uuidKey {
+			return errors.New("requires a string or [16]byte key field")
+		}
+	default:
+		return errors.New("unknown strategy, expected seq, ulid or uuid7")
+	}
+	return nil
+}
+
+// assignKeys generates keys for the values whose key field is empty, updating the values in place
+func (s *Store[T]) assignKeys(ctx context.Context, values []T) error {
+	if s.autoKey == "" {
+		return nil
+	}
+	part := s.keyParts[0]
+	var empty []reflect.Value
+	for index := range values {
+		field := reflect.ValueOf(&values[index]).Elem().Field(part.field)
+		if field.IsZero() {
+			empty = append(empty, field)
+		}
+	}
+	if len(empty) == 0 {
+		return nil
+	}
+
+	switch s.autoKey {
+	case autoKeySequence:
+		first, err := s.database.reserveSequence(s.bucket, uint64(len(empty)), func() (uint64, error) {
+			return s.lastSequence(ctx)
+		})
+		if err != nil {
+			return err
+		}
+		for index, field := range empty {
+			sequence := first + uint64(index)
+			if part.kind == signedKey {
+				if sequence > 1<<63-1 || field.OverflowInt(int64(sequence)) {
+					return InvalidKeyError{Key: strconv.FormatUint(sequence, 10)}
+				}
+				field.SetInt(int64(sequence))
+			} else {
+				if field.OverflowUint(sequence) {
+					return InvalidKeyError{Key: strconv.FormatUint(sequence, 10)}
+				}
+				field.SetUint(sequence)
+			}
+		}
+	case autoKeyULID:
+		for _, field := range empty {
+			id := newULID()
+			if part.kind == stringKey {
+				field.SetString(formatULID(id))
+			} else {
+				reflect.Copy(field, reflect.ValueOf(id[:]))
+			}
+		}
+	case autoKeyUUID7:
+		for _, field := range empty {
+			id := newUUID7()
+			if part.kind == stringKey {
+				field.SetString(formatUUID(id))
+			} else {
+				reflect.Copy(field, reflect.ValueOf(id[:]))
+			}
+		}
+	}
+	return nil
+}
+
+// keySequence returns the key of a record as a bucket sequence when keys are generated from the sequence.
+// Keys given by the caller also advance the sequence, so generated keys never collide with them.
+func (s *Store[T]) keySequence(value T) uint64 {
+	if s.autoKey != autoKeySequence {
+		return 0
+	}
+	key := reflect.ValueOf(value).Field(s.keyParts[0].field)
+	if s.keyParts[0].kind == signedKey {
+		if key.Int() <= 0 {
+			return 0
+		}
+		return uint64(key.Int())
+	}
+	return key.Uint()
+}
+
+// lastSequence returns the highest sequence used by the bucket, taking keys written without the sequence into account
+func (s *Store[T]) lastSequence(ctx context.Context) (uint64, error) {
+	var last uint64
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		last = snapshot.sequence(s.bucket)
+		keyBytes, _ := snapshot.cursor(s.bucket).Last()
+		if keyBytes == nil {
+			return nil
+		}
+		switch key := reflect.ValueOf(s.decodeKey(string(keyBytes))); s.keyParts[0].kind {
+		case signedKey:
+			if key.Int() > 0 && uint64(key.Int()) > last {
+				last = uint64(key.Int())
+			}
+		case unsignedKey:
+			if key.Uint() > last {
+				last = key.Uint()
+			}
+		}
+		return nil
+	})
+	return last, err
+}
+
+// monotonicGenerator creates 128-bit identifiers that start with a millisecond timestamp followed by
+// random bits. Identifiers created within the same millisecond increment the random bits of the
+// previous one, so identifiers from one process always sort in the order they were created.
+type monotonicGenerator struct {
+	mutex    sync.Mutex
+	bits     int // number of random bits, at most 80
+	lastTime int64
+	random   [10]byte // random bits aligned to the right
+}
+
+var (
+	ulidGenerator  = &monotonicGenerator{bits: 80}
+	uuid7Generator = &monotonicGenerator{bits: 74} // the other 6 bits hold the version and variant
+)
+
+// next returns the timestamp and random bits of the next identifier
+func (g *monotonicGenerator) next() (int64, [10]byte) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	now := time.Now().UnixMilli()
+	if now > g.lastTime {
+		g.lastTime = now
+		g.randomize()
+	} else if !g.increment() {
+		// The random bits ran out, so move on to the next millisecond
+		g.lastTime++
+		g.randomize()
+	}
+	return g.lastTime, g.random
+}
+
+// randomize fills the random bits, leaving the highest one clear to make room for increments
+func (g *monotonicGenerator) randomize() {
+	rand.Read(g.random[:])
+	g.random[0] &= 0xff >> (80 - g.bits + 1)
+}
+
+// increment adds one to the random bits and reports whether they did not overflow
+func (g *monotonicGenerator) increment() bool {
+	for index := len(g.random) - 1; index >= 0; index-- {
+		g.random[index]++
+		if g.random[index] != 0 {
+			return int(g.random[0]) < 1<<(g.bits-72)
+		}
+	}
+	return false
+}
+
+// newULID returns the next monotonic ULID
+func newULID() [16]byte {
+	timestamp, random := ulidGenerator.next()
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(timestamp)<<16)
+	copy(id[6:], random[:])
+	return id
+}
+
+// newUUID7 returns the next monotonic version 7 UUID
+func newUUID7() [16]byte {
+	timestamp, random := uuid7Generator.next()
+	// Split the 74 random bits into the 12 bits before and the 62 bits after the variant
+	high := uint64(random[0])<<8 | uint64(random[1])
+	low := binary.BigEndian.Uint64(random[2:])
+	randomA := high<<2 | low>>62
+	randomB := low & (1<<62 - 1)
+
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(timestamp)<<16|0x7000|randomA)
+	binary.BigEndian.PutUint64(id[8:], 1<<63|randomB)
+	return id
+}
+
+// crockfordAlphabet is the base32 alphabet of ULIDs, in ascending byte order
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// formatULID encodes an identifier as a 26 character ULID string
+func formatULID(id [16]byte) string {
+	high := binary.BigEndian.Uint64(id[:8])
+	low := binary.BigEndian.Uint64(id[8:])
+	encoded := make([]byte, 26)
+	for index := len(encoded) - 1; index >= 0; index-- {
+		encoded[index] = crockfordAlphabet[low&0x1f]
+		low = low>>5 | high<<59
+		high >>= 5
+	}
+	return string(encoded)
+}
+
+// formatUUID encodes an identifier in the canonical hyphenated form
+func formatUUID(id [16]byte) string {
+	encoded := hex.EncodeToString(id[:])
+	return encoded[:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:]
+}
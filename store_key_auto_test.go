@@ -0,0 +1,243 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+type TestTicket struct {
+	ID    int `nnut:"key,auto=seq"`
+	Title string
+}
+
+type TestOrder struct {
+	ID    string `nnut:"key,auto=ulid"`
+	Total int
+}
+
+type TestSession struct {
+	ID   [16]byte `nnut:"key,auto=uuid7"`
+	User string
+}
+
+func TestAutoKeySequence(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestTicket](db, "tickets")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ticket := TestTicket{Title: "first"}
+	err = store.PutPtr(context.Background(), &ticket)
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	if ticket.ID != 1 {
+		t.Fatalf("Expected key 1, got %d", ticket.ID)
+	}
+
+	tickets := []*TestTicket{{Title: "second"}, {ID: 10, Title: "given"}, {Title: "third"}}
+	err = store.PutBatchPtr(context.Background(), tickets)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	if tickets[0].ID != 2 || tickets[1].ID != 10 || tickets[2].ID != 3 {
+		t.Fatalf("Expected keys 2, 10 and 3, got %d, %d and %d", tickets[0].ID, tickets[1].ID, tickets[2].ID)
+	}
+
+	// Put by value stores the record under a generated key without changing the caller's copy
+	value := TestTicket{Title: "by value"}
+	err = store.Put(context.Background(), value)
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	if value.ID != 0 {
+		t.Fatalf("Expected the caller's value to be unchanged, got key %d", value.ID)
+	}
+	result, err := store.Get(context.Background(), 11)
+	if err != nil {
+		t.Fatalf("Failed to get generated key after a given key: %v", err)
+	}
+	if result.Title != "by value" {
+		t.Fatalf("Expected 'by value', got %s", result.Title)
+	}
+
+	// The sequence continues after replaying the WAL on reopen
+	db.Close()
+	db, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen DB: %v", err)
+	}
+	defer db.Close()
+	store, err = NewStore[TestTicket](db, "tickets")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ticket = TestTicket{Title: "after reopen"}
+	err = store.PutPtr(context.Background(), &ticket)
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	if ticket.ID != 12 {
+		t.Fatalf("Expected key 12, got %d", ticket.ID)
+	}
+
+	// Flushing keeps the sequence
+	db.Flush()
+	ticket = TestTicket{Title: "after flush"}
+	err = store.PutPtr(context.Background(), &ticket)
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	if ticket.ID != 13 {
+		t.Fatalf("Expected key 13, got %d", ticket.ID)
+	}
+	count, err := store.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 7 {
+		t.Fatalf("Expected 7 records, got %d", count)
+	}
+}
+
+func TestAutoKeyULID(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestOrder](db, "orders")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	orders := make([]*TestOrder, 100)
+	for index := range orders {
+		orders[index] = &TestOrder{Total: index}
+	}
+	err = store.PutBatchPtr(context.Background(), orders)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// Keys created in order sort in that order
+	keys := make([]string, len(orders))
+	for index, order := range orders {
+		if len(order.ID) != 26 {
+			t.Fatalf("Expected a 26 character ULID, got %q", order.ID)
+		}
+		keys[index] = order.ID
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("Expected ULIDs in creation order, got %v", keys)
+	}
+	results, err := store.GetQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != len(orders) {
+		t.Fatalf("Expected %d results, got %d", len(orders), len(results))
+	}
+	for index, result := range results {
+		if result.Total != index {
+			t.Fatalf("Expected total %d at position %d, got %d", index, index, result.Total)
+		}
+	}
+}
+
+func TestAutoKeyUUID7(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestSession](db, "sessions")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	first := TestSession{User: "alice"}
+	err = store.PutPtr(context.Background(), &first)
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	second := TestSession{User: "bob"}
+	err = store.PutPtr(context.Background(), &second)
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	// The version and variant bits are set
+	if first.ID[6]>>4 != 7 || first.ID[8]>>6 != 2 {
+		t.Fatalf("Expected a version 7 UUID, got %x", first.ID)
+	}
+	if string(first.ID[:]) >= string(second.ID[:]) {
+		t.Fatalf("Expected %x to sort before %x", first.ID, second.ID)
+	}
+	result, err := store.Get(context.Background(), second.ID)
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if result.User != "bob" {
+		t.Fatalf("Expected bob, got %s", result.User)
+	}
+}
+
+func TestAutoKeyInvalid(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type unknownStrategy struct {
+		ID int `nnut:"key,auto=random"`
+	}
+	_, err = NewStore[unknownStrategy](db, "unknown")
+	if _, ok := err.(AutoKeyError); !ok {
+		t.Fatalf("Expected AutoKeyError, got %v", err)
+	}
+
+	type stringSequence struct {
+		ID string `nnut:"key,auto=seq"`
+	}
+	_, err = NewStore[stringSequence](db, "sequence")
+	if _, ok := err.(AutoKeyError); !ok {
+		t.Fatalf("Expected AutoKeyError, got %v", err)
+	}
+
+	type compositeKey struct {
+		Tenant string `nnut:"key:1"`
+		ID     int    `nnut:"key:2,auto=seq"`
+	}
+	_, err = NewStore[compositeKey](db, "composite")
+	if _, ok := err.(AutoKeyError); !ok {
+		t.Fatalf("Expected AutoKeyError, got %v", err)
+	}
+}
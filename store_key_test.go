@@ -0,0 +1,456 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type TestEvent struct {
+	ID   int `nnut:"key"`
+	Name string
+}
+
+type TestCounter struct {
+	ID    uint64 `nnut:"key"`
+	Value int
+}
+
+type TestDevice struct {
+	ID   [16]byte `nnut:"key"`
+	Name string   `nnut:"index:name"`
+}
+
+type TestMembership struct {
+	Tenant string `nnut:"key:1"`
+	ID     int    `nnut:"key:2"`
+	Role   string `nnut:"index:role"`
+}
+
+type TestReading struct {
+	At    time.Time `nnut:"key"`
+	Value int
+}
+
+func TestIntKeys(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestEvent](db, "events")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testEvents := []TestEvent{
+		{ID: 10, Name: "ten"},
+		{ID: -5, Name: "minus five"},
+		{ID: 0, Name: "zero"},
+		{ID: 256, Name: "two hundred fifty-six"},
+		{ID: -300, Name: "minus three hundred"},
+	}
+	err = store.PutBatch(context.Background(), testEvents)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+
+	// Keys are stored in numeric order, negative numbers first
+	results, err := store.GetQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	expected := []int{-300, -5, 0, 10, 256}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for index, event := range results {
+		if event.ID != expected[index] {
+			t.Fatalf("Expected key %d at position %d, got %d", expected[index], index, event.ID)
+		}
+	}
+
+	event, err := store.Get(context.Background(), -5)
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if event.Name != "minus five" {
+		t.Fatalf("Expected minus five, got %s", event.Name)
+	}
+	// Other integer types are accepted when the value fits
+	_, err = store.Get(context.Background(), int64(256))
+	if err != nil {
+		t.Fatalf("Failed to get with int64 key: %v", err)
+	}
+
+	batch, err := store.GetBatchByKeys(context.Background(), []int{10, 0, 99})
+	if err != nil {
+		t.Fatalf("Failed to get batch: %v", err)
+	}
+	if len(batch) != 3 || batch[0] == nil || batch[0].Name != "ten" || batch[1] == nil || batch[1].Name != "zero" || batch[2] != nil {
+		t.Fatalf("Unexpected batch results: %v", batch)
+	}
+	keyed, err := store.GetBatch(context.Background(), []int64{-5, 256, 99})
+	if err != nil {
+		t.Fatalf("Failed to get batch: %v", err)
+	}
+	if len(keyed) != 2 || keyed["-5"].Name != "minus five" || keyed["256"].Name != "two hundred fifty-six" {
+		t.Fatalf("Unexpected keyed batch results: %v", keyed)
+	}
+
+	err = store.Delete(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	_, err = store.Get(context.Background(), 10)
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError, got %v", err)
+	}
+	if err.(KeyNotFoundError).Key != "10" {
+		t.Fatalf("Expected readable key in error, got %q", err.(KeyNotFoundError).Key)
+	}
+
+	// Keys of another type are rejected
+	_, err = store.Get(context.Background(), "10")
+	if _, ok := err.(InvalidFieldTypeError); !ok {
+		t.Fatalf("Expected InvalidFieldTypeError, got %v", err)
+	}
+}
+
+func TestUnsignedKeys(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestCounter](db, "counters")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testCounters := []TestCounter{
+		{ID: 1 << 63, Value: 3},
+		{ID: 1, Value: 1},
+		{ID: 1 << 32, Value: 2},
+	}
+	err = store.PutBatch(context.Background(), testCounters)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	results, err := store.GetQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	for index, counter := range results {
+		if counter.Value != index+1 {
+			t.Fatalf("Expected value %d at position %d, got %d", index+1, index, counter.Value)
+		}
+	}
+
+	// Negative keys cannot match an unsigned key field
+	_, err = store.Get(context.Background(), -1)
+	if _, ok := err.(InvalidKeyError); !ok {
+		t.Fatalf("Expected InvalidKeyError, got %v", err)
+	}
+}
+
+func TestUUIDKeys(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestDevice](db, "devices")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	first := [16]byte{0x00, 0x01}
+	second := [16]byte{0x00, 0x02, 0x00, 0x00}
+	err = store.PutBatch(context.Background(), []TestDevice{
+		{ID: second, Name: "second"},
+		{ID: first, Name: "first"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	device, err := store.Get(context.Background(), second)
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if device.Name != "second" {
+		t.Fatalf("Expected second, got %s", device.Name)
+	}
+
+	// Keys containing zero bytes work with indexes
+	results, err := store.GetQuery(context.Background(), &Query{Index: "name", Sort: Descending})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != second || results[1].ID != first {
+		t.Fatalf("Unexpected query results: %v", results)
+	}
+}
+
+func TestTimeKeys(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestReading](db, "readings")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testReadings := []TestReading{
+		{At: base.Add(time.Hour), Value: 3},
+		{At: base.AddDate(-100, 0, 0), Value: 1},
+		{At: base.Add(time.Nanosecond), Value: 2},
+	}
+	err = store.PutBatch(context.Background(), testReadings)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	results, err := store.GetQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	for index, reading := range results {
+		if reading.Value != index+1 {
+			t.Fatalf("Expected value %d at position %d, got %d", index+1, index, reading.Value)
+		}
+	}
+
+	reading, err := store.Get(context.Background(), base.Add(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if reading.Value != 2 {
+		t.Fatalf("Expected value 2, got %d", reading.Value)
+	}
+}
+
+func TestUnsupportedKeyType(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type invalidKey struct {
+		ID float64 `nnut:"key"`
+	}
+	_, err = NewStore[invalidKey](db, "invalid")
+	if _, ok := err.(KeyFieldTypeError); !ok {
+		t.Fatalf("Expected KeyFieldTypeError, got %v", err)
+	}
+}
+
+func TestCompositeKeys(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestMembership](db, "memberships")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testMemberships := []TestMembership{
+		{Tenant: "acme", ID: 2, Role: "admin"},
+		{Tenant: "acme", ID: -1, Role: "member"},
+		{Tenant: "acme\x01", ID: 1, Role: "member"},
+		{Tenant: "ac", ID: 7, Role: "admin"},
+		{Tenant: "acmeco", ID: 3, Role: "member"},
+	}
+	err = store.PutBatch(context.Background(), testMemberships)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+
+	membership, err := store.GetByKey(context.Background(), "acme", 2)
+	if err != nil {
+		t.Fatalf("Failed to get by key: %v", err)
+	}
+	if membership.Role != "admin" {
+		t.Fatalf("Expected admin, got %s", membership.Role)
+	}
+	_, err = store.GetByKey(context.Background(), "acme")
+	if _, ok := err.(InvalidKeyError); !ok {
+		t.Fatalf("Expected InvalidKeyError for a partial key, got %v", err)
+	}
+
+	// Records are ordered by each key part in turn
+	results, err := store.GetQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	expected := []string{"ac/7", "acme/-1", "acme/2", "acme\x01/1", "acmeco/3"}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for index, result := range results {
+		key, _ := store.keyOf(result)
+		if store.formatKey(key) != expected[index] {
+			t.Fatalf("Expected %q at position %d, got %q", expected[index], index, store.formatKey(key))
+		}
+	}
+
+	// A key prefix selects all rows of one tenant only
+	results, err = store.GetQuery(context.Background(), &Query{KeyPrefix: []interface{}{"acme"}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != -1 || results[1].ID != 2 {
+		t.Fatalf("Unexpected prefix results: %v", results)
+	}
+	count, err := store.CountQuery(context.Background(), &Query{
+		KeyPrefix:  []interface{}{"acme"},
+		Conditions: []Condition{{Field: "Role", Value: "member"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected count 1, got %d", count)
+	}
+
+	batch, err := store.GetBatchByKeys(context.Background(), [][]interface{}{{"acme", 2}, {"ac", 7}})
+	if err != nil {
+		t.Fatalf("Failed to get batch: %v", err)
+	}
+	if len(batch) != 2 || batch[0] == nil || batch[0].Role != "admin" || batch[1] == nil {
+		t.Fatalf("Unexpected batch results: %v", batch)
+	}
+
+	deletedCount, err := store.DeleteQuery(context.Background(), &Query{KeyPrefix: []interface{}{"acme"}})
+	if err != nil {
+		t.Fatalf("Failed to delete query: %v", err)
+	}
+	if deletedCount != 2 {
+		t.Fatalf("Expected 2 deletions, got %d", deletedCount)
+	}
+	count, err = store.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("Expected count 3, got %d", count)
+	}
+}
+
+func TestCompositeKeyOrder(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type duplicateOrder struct {
+		Tenant string `nnut:"key:1"`
+		ID     string `nnut:"key:1"`
+	}
+	_, err = NewStore[duplicateOrder](db, "duplicate")
+	if _, ok := err.(KeyFieldOrderError); !ok {
+		t.Fatalf("Expected KeyFieldOrderError, got %v", err)
+	}
+
+	type unnumbered struct {
+		Tenant string `nnut:"key"`
+		ID     string `nnut:"key:2"`
+	}
+	_, err = NewStore[unnumbered](db, "unnumbered")
+	if _, ok := err.(KeyFieldOrderError); !ok {
+		t.Fatalf("Expected KeyFieldOrderError, got %v", err)
+	}
+}
+
+func TestGetBatchByCompositeKeys(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	type TestPath struct {
+		Folder string `nnut:"key:1"`
+		Name   string `nnut:"key:2"`
+		Size   int
+	}
+	store, err := NewStore[TestPath](db, "paths")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.PutBatch(context.Background(), []TestPath{
+		{Folder: "a/b", Name: "c", Size: 1},
+		{Folder: "a", Name: "b/c", Size: 2},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// Keys that format alike are still told apart, and results follow the order of the keys
+	batch, err := store.GetBatchByKeys(context.Background(), [][]interface{}{{"a", "b/c"}, {"a", "b"}, {"a/b", "c"}})
+	if err != nil {
+		t.Fatalf("Failed to get batch: %v", err)
+	}
+	if len(batch) != 3 || batch[0] == nil || batch[0].Size != 2 || batch[1] != nil || batch[2] == nil || batch[2].Size != 1 {
+		t.Fatalf("Unexpected batch results: %v", batch)
+	}
+
+	// Slashes within key parts are escaped in the keys of the results
+	keyed, err := store.GetBatch(context.Background(), [][]interface{}{{"a", "b/c"}, {"a/b", "c"}})
+	if err != nil {
+		t.Fatalf("Failed to get batch: %v", err)
+	}
+	if len(keyed) != 2 || keyed[`a/b\/c`].Size != 2 || keyed[`a\/b/c`].Size != 1 {
+		t.Fatalf("Unexpected keyed batch results: %v", keyed)
+	}
+}
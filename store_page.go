@@ -0,0 +1,201 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Page is a page of query results with cursors to continue from
+type Page[T any] struct {
+	Items      []T
+	NextCursor string // Pass as After to get the page following this one, empty when no records follow
+	PrevCursor string // Pass as Before to get the page preceding this one, empty when no records precede
+}
+
+// pageCursor is the position of a record in the order of a query, as handed out in an opaque cursor
+type pageCursor struct {
+	Order  string        `msgpack:"o"`
+	Values []interface{} `msgpack:"v"`
+	Key    string        `msgpack:"k"`
+}
+
+// GetPage queries for a page of records matching the conditions, starting past the After or Before cursor.
+// Each cursor holds the sort values and key of a record, so pages seek to it and stay consistent under writes.
+func (s *Store[T]) GetPage(ctx context.Context, query *Query) (Page[T], error) {
+	if err := s.validateQuery(query); err != nil {
+		return Page[T]{}, err
+	}
+	var page Page[T]
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		page, err = s.In(snapshot).GetPage(ctx, query)
+		return err
+	})
+	return page, err
+}
+
+// GetPage queries for a page of records matching the conditions as of the snapshot
+func (v *StoreView[T]) GetPage(ctx context.Context, query *Query) (Page[T], error) {
+	var page Page[T]
+	if err := v.store.validateQuery(query); err != nil {
+		return page, err
+	}
+	select {
+	case <-ctx.Done():
+		return page, ctx.Err()
+	default:
+	}
+
+	// One record beyond the page tells whether another page follows
+	pageQuery := *query
+	if query.Limit > 0 {
+		pageQuery.Limit = query.Limit + 1
+	}
+	keys, err := v.getQueryKeys(&pageQuery)
+	if err != nil {
+		return page, err
+	}
+	backward := query.Before != ""
+	more := query.Limit > 0 && len(keys) > query.Limit
+	if more && backward {
+		keys = keys[1:]
+	} else if more {
+		keys = keys[:query.Limit]
+	}
+
+	var pageKeys []string
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for _, key := range keys {
+		data := v.snapshot.get(v.store.bucket, []byte(key))
+		if data == nil {
+			continue
+		}
+		var item T
+		decoder.Reset(bytes.NewReader(data))
+		if err := decoder.Decode(&item); err != nil {
+			continue
+		}
+		page.Items = append(page.Items, item)
+		pageKeys = append(pageKeys, key)
+	}
+	if len(page.Items) == 0 {
+		return page, nil
+	}
+
+	order := v.store.resultOrder(query)
+	if more || backward {
+		last := len(page.Items) - 1
+		page.NextCursor = v.store.encodeCursor(order, pageKeys[last], page.Items[last])
+	}
+	if (more && backward) || query.After != "" {
+		page.PrevCursor = v.store.encodeCursor(order, pageKeys[0], page.Items[0])
+	}
+	return page, nil
+}
+
+// encodeCursor encodes the position of a record in an order into a cursor
+func (s *Store[T]) encodeCursor(order resultOrder, key string, item T) string {
+	encoded, err := msgpack.Marshal(pageCursor{
+		Order:  order.signature(),
+		Values: s.sortValues(reflect.ValueOf(item), order.fields),
+		Key:    key,
+	})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor decodes a cursor into the position of a record, reporting false when it does not belong to the order
+func (s *Store[T]) decodeCursor(cursor string, order resultOrder) (sortEntry, bool) {
+	var decoded pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = msgpack.Unmarshal(data, &decoded)
+	}
+	if err != nil || decoded.Order != order.signature() || len(decoded.Values) != len(order.fields) {
+		return sortEntry{}, false
+	}
+	for position, value := range decoded.Values {
+		if _, ok := s.encodeFieldValue(order.fields[position], value); value != nil && !ok {
+			return sortEntry{}, false
+		}
+	}
+	return sortEntry{key: decoded.Key, values: decoded.Values}, true
+}
+
+// signature describes the order, so that cursors are only used with queries sharing it
+func (order resultOrder) signature() string {
+	return fmt.Sprint(order.fields, order.desc, order.keyDesc)
+}
+
+// getKeysPastKey returns the keys matching the filter of a query in key order past the key of the entry after,
+// sharing the key prefix and leaving out soft-deleted records when requested, up to maxKeys if >0
+func (v *StoreView[T]) getKeysPastKey(query *Query, after sortEntry, descending bool, keyPrefix string, excludeDeleted bool, maxKeys int) []string {
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	keep := func(key string) bool {
+		return strings.HasPrefix(key, keyPrefix) && (!excludeDeleted || v.snapshot.get(tombstoneBucket, []byte(key)) == nil)
+	}
+
+	var keys []string
+	if query.hasFilter() {
+		candidates := v.getFilterKeys(query, 0)
+		for position := range candidates {
+			key := candidates[position]
+			if descending {
+				key = candidates[len(candidates)-1-position]
+			}
+			if maxKeys > 0 && len(keys) >= maxKeys {
+				break
+			}
+			if (key > after.key) != descending && key != after.key && keep(key) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+
+	// Without a filter the walk starts right at the cursor
+	cursor := v.snapshot.cursor(v.store.bucket)
+	for keyBytes, _ := seekPast(cursor, []byte(after.key), descending); keyBytes != nil && (maxKeys == 0 || len(keys) < maxKeys); keyBytes, _ = stepCursor(cursor, descending) {
+		key := string(keyBytes)
+		if !strings.HasPrefix(key, keyPrefix) {
+			if (key > keyPrefix) != descending {
+				break
+			}
+			continue
+		}
+		if keep(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// seekPast moves the cursor to the first key past seek in the walk direction, or to the start when seek is nil
+func seekPast(cursor *overlayCursor, seek []byte, descending bool) ([]byte, []byte) {
+	if seek == nil {
+		if descending {
+			return cursor.Last()
+		}
+		return cursor.First()
+	}
+	keyBytes, data := cursor.Seek(seek)
+	if descending {
+		if keyBytes == nil {
+			return cursor.Last()
+		}
+		return cursor.Prev()
+	}
+	if keyBytes != nil && bytes.Equal(keyBytes, seek) {
+		return cursor.Next()
+	}
+	return keyBytes, data
+}
@@ -0,0 +1,194 @@
+package nnut
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetPage(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestUser](db, "users", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	var users []TestUser
+	for i := 0; i < 25; i++ {
+		users = append(users, TestUser{UUID: fmt.Sprintf("%02d", i), Name: []string{"ana", "bob", "eve"}[i%3], Email: fmt.Sprintf("%02d@example.com", 24-i), Age: i % 4})
+	}
+	err = store.PutBatch(context.Background(), users[:12])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), users[12:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Delete(context.Background(), "07")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	ids := func(results []TestUser) string {
+		var ids string
+		for _, result := range results {
+			ids += result.UUID + " "
+		}
+		return ids
+	}
+
+	for _, query := range []Query{
+		{},
+		{Conditions: []Condition{{Field: "Name", Value: "eve", Operator: NotEquals}}},
+		{Index: "age"},
+		{Index: "age", Sort: Descending},
+		{Index: "email", Sort: Descending, Conditions: []Condition{{Field: "Name", Value: "bob", Operator: NotEquals}}},
+		{Index: "age", Where: Or(Condition{Field: "Name", Value: "ana"}, Condition{Field: "Age", Value: 3})},
+		{OrderBy: []SortKey{{Field: "Age", Desc: true}, {Field: "Name"}}},
+		{OrderBy: []SortKey{{Field: "Name"}}, Conditions: []Condition{{Field: "Age", Value: 1, Operator: GreaterThan}}},
+	} {
+		expected, err := store.GetQuery(context.Background(), &query)
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+
+		// Following the next cursors visits every record once, in order
+		var forward []TestUser
+		var pages []Page[TestUser]
+		pageQuery := query
+		pageQuery.Limit = 4
+		for {
+			page, err := store.GetPage(context.Background(), &pageQuery)
+			if err != nil {
+				t.Fatalf("Failed to get page of %+v: %v", query, err)
+			}
+			if len(page.Items) > 4 {
+				t.Fatalf("Expected at most 4 records, got %d", len(page.Items))
+			}
+			if (len(pages) == 0) != (page.PrevCursor == "") {
+				t.Fatalf("Expected a previous cursor on all but the first page of %+v", query)
+			}
+			forward = append(forward, page.Items...)
+			pages = append(pages, page)
+			if page.NextCursor == "" {
+				break
+			}
+			pageQuery.After = page.NextCursor
+		}
+		if ids(forward) != ids(expected) {
+			t.Fatalf("Expected %s paging %+v, got %s", ids(expected), query, ids(forward))
+		}
+
+		// Following the previous cursors from the last page visits the same pages backwards
+		backward := pages[len(pages)-1].Items
+		pageQuery = query
+		pageQuery.Limit = 4
+		for position := len(pages) - 1; pages[position].PrevCursor != ""; position-- {
+			pageQuery.After = ""
+			pageQuery.Before = pages[position].PrevCursor
+			page, err := store.GetPage(context.Background(), &pageQuery)
+			if err != nil {
+				t.Fatalf("Failed to get page of %+v: %v", query, err)
+			}
+			if ids(page.Items) != ids(pages[position-1].Items) {
+				t.Fatalf("Expected %s before page %d of %+v, got %s", ids(pages[position-1].Items), position, query, ids(page.Items))
+			}
+			if page.NextCursor == "" {
+				t.Fatalf("Expected a next cursor when paging backwards through %+v", query)
+			}
+			backward = append(append([]TestUser(nil), page.Items...), backward...)
+		}
+		if ids(backward) != ids(expected) {
+			t.Fatalf("Expected %s paging back through %+v, got %s", ids(expected), query, ids(backward))
+		}
+	}
+}
+
+func TestGetPageConcurrentWrites(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		err = store.Put(context.Background(), TestUser{UUID: fmt.Sprintf("%02d", i), Age: i * 10})
+		if err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+	}
+
+	query := &Query{Index: "age", Limit: 2}
+	page, err := store.GetPage(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Failed to get page: %v", err)
+	}
+
+	// Records written before the cursor and the removal of the cursor record do not shift the next page
+	err = store.Put(context.Background(), TestUser{UUID: "10", Age: 5})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	err = store.Delete(context.Background(), "01")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	query.After = page.NextCursor
+	page, err = store.GetPage(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Failed to get page: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].UUID != "02" || page.Items[1].UUID != "03" {
+		t.Fatalf("Expected 02 and 03, got %+v", page.Items)
+	}
+
+	// Cursors only continue queries with the same order
+	for _, query := range []*Query{
+		{Index: "age", After: "not a cursor"},
+		{Index: "age", Sort: Descending, After: page.NextCursor},
+		{OrderBy: []SortKey{{Field: "Age", Desc: true}}, After: page.NextCursor},
+		{Index: "age", After: page.NextCursor, Before: page.PrevCursor},
+		{Index: "age", After: page.NextCursor, Offset: 2},
+	} {
+		_, err := store.GetPage(context.Background(), query)
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Fatalf("Expected InvalidQueryError for %+v, got %v", query, err)
+		}
+	}
+
+	// Queries continue past a cursor as well
+	results, err := store.GetQuery(context.Background(), &Query{Index: "age", After: page.NextCursor})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 2 || results[0].UUID != "04" || results[1].UUID != "05" {
+		t.Fatalf("Expected 04 and 05, got %+v", results)
+	}
+	count, err := store.CountQuery(context.Background(), &Query{Index: "age", Before: page.PrevCursor})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 records before the page, got %d", count)
+	}
+}
@@ -3,70 +3,100 @@ package nnut
 import (
 	"bytes"
 	"context"
-	"reflect"
 
 	"github.com/vmihailenco/msgpack/v5"
 )
 
 // Persist a single record with index updates
 func (s *Store[T]) Put(ctx context.Context, value T) error {
+	return s.put(ctx, &value)
+}
+
+// PutPtr persists a single record like Put and writes a generated key back into the value
+func (s *Store[T]) PutPtr(ctx context.Context, value *T) error {
+	return s.put(ctx, value)
+}
+
+// put persists a single record, generating its key first when the key field is empty
+func (s *Store[T]) put(ctx context.Context, record *T) error {
+	values := []T{*record}
+	if err := s.assignKeys(ctx, values); err != nil {
+		return err
+	}
+	value := values[0]
+
 	// Retrieve the primary key via runtime type inspection
-	valueReflection := reflect.ValueOf(value)
-	key := valueReflection.Field(s.keyField).String()
-	if err := validateKey(key); err != nil {
+	key, err := s.keyOf(value)
+	if err != nil {
 		return err
 	}
 
 	// Fetch existing record to handle index changes
-	var oldIndexValues map[string]string
-	oldValue, err := s.Get(ctx, key)
+	var oldIndexValues map[string][]string
+	oldValue, _, err := s.getWithDeleted(ctx, key)
 	if err == nil {
 		oldIndexValues = s.extractIndexValues(oldValue)
-	} else {
-		oldIndexValues = make(map[string]string)
 	}
 
-	newIndexValues := s.extractIndexValues(value)
-
 	// Prepare index maintenance operations
-	var indexOperations []indexOperation
-	for name := range s.indexFields {
-		oldValue := oldIndexValues[name]
-		newValue := newIndexValues[name]
-		if oldValue != newValue {
-			indexOperations = append(indexOperations, indexOperation{
-				IndexName: name,
-				OldValue:  oldValue,
-				NewValue:  newValue,
-			})
-		}
-	}
+	indexOperations := s.indexOperations(oldIndexValues, s.extractIndexValues(value))
 
 	data, err := msgpack.Marshal(value)
 	if err != nil {
-		return WrappedError{Operation: "marshal", Bucket: string(s.bucket), Key: key, Err: err}
+		return WrappedError{Operation: "marshal", Bucket: string(s.bucket), Key: s.formatKey(key), Err: err}
 	}
 
-	operation := operation{
+	operations := []operation{{
 		Bucket:          s.bucket,
 		Key:             key,
 		Value:           data,
 		IsPut:           true,
 		IndexOperations: indexOperations,
+		KeySequence:     s.keySequence(value),
+	}}
+	err = s.database.writeCheckedOperations(ctx, operations, s.checkUnique(operations))
+	if err != nil {
+		return err
 	}
-
-	return s.database.writeOperation(ctx, operation)
+	*record = value
+	return nil
 }
 
 // Persist multiple records efficiently
 func (s *Store[T]) PutBatch(ctx context.Context, values []T) error {
+	records := make([]T, len(values))
+	copy(records, values)
+	return s.putBatch(ctx, records)
+}
+
+// PutBatchPtr persists multiple records like PutBatch and writes generated keys back into the values
+func (s *Store[T]) PutBatchPtr(ctx context.Context, values []*T) error {
+	records := make([]T, len(values))
+	for index, value := range values {
+		records[index] = *value
+	}
+	err := s.putBatch(ctx, records)
+	if err != nil {
+		return err
+	}
+	for index, value := range values {
+		*value = records[index]
+	}
+	return nil
+}
+
+// putBatch persists multiple records, generating keys first for those with an empty key field
+func (s *Store[T]) putBatch(ctx context.Context, values []T) error {
+	if err := s.assignKeys(ctx, values); err != nil {
+		return err
+	}
+
 	// Collect primary keys from all values
 	keys := make([]string, len(values))
 	keyToValue := make(map[string]T)
 	for index, value := range values {
-		valueReflection := reflect.ValueOf(value)
-		key := valueReflection.Field(s.keyField).String()
-		if err := validateKey(key); err != nil {
+		key, err := s.keyOf(value)
+		if err != nil {
 			return err
 		}
 		keys[index] = key
@@ -74,7 +104,7 @@ func (s *Store[T]) PutBatch(ctx context.Context, values []T) error {
 	}
 
 	// Retrieve existing records for index updates
-	oldValues, err := s.GetBatch(ctx, keys)
+	oldValues, err := s.getBatch(ctx, keys, true)
 	if err != nil {
 		return WrappedError{Operation: "get_batch", Bucket: string(s.bucket), Err: err}
 	}
@@ -84,28 +114,13 @@ func (s *Store[T]) PutBatch(ctx context.Context, values []T) error {
 	for _, key := range keys {
 		value := keyToValue[key]
 		oldValue, exists := oldValues[key]
-		var oldIndexValues map[string]string
+		var oldIndexValues map[string][]string
 		if exists {
 			oldIndexValues = s.extractIndexValues(oldValue)
-		} else {
-			oldIndexValues = make(map[string]string)
 		}
 
-		newIndexValues := s.extractIndexValues(value)
-
 		// Set up index modifications
-		var indexOperations []indexOperation
-		for name := range s.indexFields {
-			oldValue := oldIndexValues[name]
-			newValue := newIndexValues[name]
-			if oldValue != newValue {
-				indexOperations = append(indexOperations, indexOperation{
-					IndexName: name,
-					OldValue:  oldValue,
-					NewValue:  newValue,
-				})
-			}
-		}
+		indexOperations := s.indexOperations(oldIndexValues, s.extractIndexValues(value))
 
 		buf := bufferPool.Get().(*bytes.Buffer)
 		defer bufferPool.Put(buf)
@@ -113,9 +128,10 @@ func (s *Store[T]) PutBatch(ctx context.Context, values []T) error {
 		encoder := msgpack.NewEncoder(buf)
 		err = encoder.Encode(value)
 		if err != nil {
-			return WrappedError{Operation: "encode", Bucket: string(s.bucket), Key: key, Err: err}
+			return WrappedError{Operation: "encode", Bucket: string(s.bucket), Key: s.formatKey(key), Err: err}
 		}
-		data := buf.Bytes()
+		// The buffered operation outlives the pooled buffer, so keep a copy
+		data := append([]byte(nil), buf.Bytes()...)
 
 		operation := operation{
 			Bucket:          s.bucket,
@@ -123,9 +139,10 @@ func (s *Store[T]) PutBatch(ctx context.Context, values []T) error {
 			Value:           data,
 			IsPut:           true,
 			IndexOperations: indexOperations,
+			KeySequence:     s.keySequence(value),
 		}
 		operations = append(operations, operation)
 	}
 
-	return s.database.writeOperations(ctx, operations)
+	return s.database.writeCheckedOperations(ctx, operations, s.checkUnique(operations))
 }
@@ -4,10 +4,11 @@ import (
 	"bytes"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/vmihailenco/msgpack/v5"
-	"go.etcd.io/bbolt"
 )
 
 var bufferPool = sync.Pool{
@@ -30,6 +31,15 @@ const (
 	LessThan
 	GreaterThanOrEqual
 	LessThanOrEqual
+	Contains    // A slice or map field holds the value, as an element or map key, or a string field holds the text
+	ContainsAny // A slice or map field holds any of the values in a slice
+	ContainsAll // A slice or map field holds all of the values in a slice
+	HasPrefix   // A string field starts with the text
+	Like        // A string field matches a pattern where % matches any text and _ a single character
+	Regexp      // A string field matches a regular expression, given as a string or *regexp.Regexp
+	NotEquals   // The field differs from the value
+	In          // The field equals any of the values in a slice
+	NotIn       // The field equals none of the values in a slice
 )
 
 type Sorting int
@@ -40,6 +50,12 @@ const (
 	Descending
 )
 
+// SortKey orders query results by a field, ascending unless Desc is set
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
 type Condition struct {
 	Field    string
 	Value    interface{}
@@ -52,7 +68,18 @@ type Query struct {
 	Offset int // Number of results to skip
 	Sort   Sorting
 
+	After  string // Continue past the record of a cursor from a page, in the order of the query
+	Before string // Continue before the record of a cursor from a page, in the order of the query
+
+	OrderBy []SortKey // Fields to order results by, in order of precedence, with ties ordered by key
+	GroupBy []string  // Fields to group records by, only used by Aggregate
+
 	Conditions []Condition
+	Where      Expression // Conditions combined with And, Or and Not, matched together with Conditions
+
+	KeyPrefix []interface{} // Leading parts of a composite key that all results share
+
+	IncludeDeleted bool // Include soft-deleted records in the results
 }
 
 type condWithSize struct {
@@ -71,56 +98,155 @@ func (s *Store[T]) validateQuery(query *Query) error {
 	if query.Offset < 0 {
 		return InvalidQueryError{Field: "Offset", Value: query.Offset, Reason: "cannot be negative"}
 	}
+	if query.After != "" || query.Before != "" {
+		if query.After != "" && query.Before != "" {
+			return InvalidQueryError{Field: "Before", Value: query.Before, Reason: "cannot be combined with After"}
+		}
+		if query.Offset > 0 {
+			return InvalidQueryError{Field: "Offset", Value: query.Offset, Reason: "cannot be combined with a cursor"}
+		}
+	}
+	if len(query.GroupBy) > 0 {
+		return InvalidQueryError{Field: "GroupBy", Value: query.GroupBy, Reason: "is only used by Aggregate"}
+	}
+	if len(query.KeyPrefix) > 0 {
+		if _, err := s.encodeKeyPrefix(query.KeyPrefix); err != nil {
+			return InvalidQueryError{Field: "KeyPrefix", Value: query.KeyPrefix, Reason: err.Error()}
+		}
+	}
 	if query.Index != "" {
-		if _, exists := s.indexFields[query.Index]; !exists {
+		index, exists := s.resolveIndex(query.Index)
+		if !exists {
 			return InvalidQueryError{Field: "Index", Value: query.Index, Reason: "index field does not exist"}
 		}
+		if s.multiValue[index] {
+			return InvalidQueryError{Field: "Index", Value: query.Index, Reason: "multi-value indexes cannot order results"}
+		}
+	}
+	if len(query.OrderBy) > 0 {
+		if query.Index != "" {
+			return InvalidQueryError{Field: "OrderBy", Value: query.OrderBy, Reason: "cannot be combined with Index"}
+		}
+		for _, sortKey := range query.OrderBy {
+			fieldIndex, exists := s.resolveField(sortKey.Field)
+			if !exists {
+				return InvalidQueryError{Field: "SortKey.Field", Value: sortKey.Field, Reason: "field does not exist"}
+			}
+			if _, ok := indexValueWidth(s.fields[fieldIndex].fieldType); !ok {
+				return InvalidQueryError{Field: "SortKey.Field", Value: sortKey.Field, Reason: "must be a string, number, bool or time field"}
+			}
+		}
+	}
+	// Cursors hold the position of a record in the order they were handed out for
+	if cursor := query.After + query.Before; cursor != "" {
+		if _, ok := s.decodeCursor(cursor, s.resultOrder(query)); !ok {
+			field := "After"
+			if query.Before != "" {
+				field = "Before"
+			}
+			return InvalidQueryError{Field: field, Value: cursor, Reason: "is not a cursor of a query with this order"}
+		}
 	}
 	// Validate conditions
 	for _, cond := range query.Conditions {
-		if _, exists := s.fieldMap[cond.Field]; !exists {
-			return InvalidQueryError{Field: "Condition.Field", Value: cond.Field, Reason: "field does not exist"}
+		if err := s.validateCondition(cond); err != nil {
+			return err
+		}
+	}
+	if query.Where != nil {
+		return s.validateExpression(query.Where)
+	}
+	return nil
+}
+
+// validateCondition validates the field, operator and value of a condition
+func (s *Store[T]) validateCondition(cond Condition) error {
+	if _, exists := s.resolveField(cond.Field); !exists {
+		return InvalidQueryError{Field: "Condition.Field", Value: cond.Field, Reason: "field does not exist"}
+	}
+	switch cond.Operator {
+	case HasPrefix, Like:
+		if _, ok := cond.Value.(string); !ok {
+			return InvalidQueryError{Field: "Condition.Value", Value: cond.Value, Reason: "must be a string"}
+		}
+		return nil
+	case Regexp:
+		if _, err := conditionPattern(cond.Value); err != nil {
+			return InvalidQueryError{Field: "Condition.Value", Value: cond.Value, Reason: "must be a regular expression"}
 		}
-		// Check if value is comparable (string or int)
-		if cond.Value != nil {
-			switch cond.Value.(type) {
-			case string, int:
-				// ok
-			default:
-				return InvalidQueryError{Field: "Condition.Value", Value: cond.Value, Reason: "must be string or int"}
+		return nil
+	}
+	if cond.Operator == ContainsAny || cond.Operator == ContainsAll || cond.Operator == In || cond.Operator == NotIn {
+		elements, ok := conditionElements(cond.Value)
+		if !ok {
+			return InvalidQueryError{Field: "Condition.Value", Value: cond.Value, Reason: "must be a slice"}
+		}
+		if len(elements) == 0 && (cond.Operator == ContainsAny || cond.Operator == ContainsAll) {
+			return InvalidQueryError{Field: "Condition.Value", Value: cond.Value, Reason: "must be a non-empty slice"}
+		}
+		for _, element := range elements {
+			if element == nil {
+				return InvalidQueryError{Field: "Condition.Value", Value: cond.Value, Reason: "must hold strings, numbers, bools or times"}
+			}
+			if _, ok := indexValueWidth(reflect.TypeOf(element)); !ok {
+				return InvalidQueryError{Field: "Condition.Value", Value: cond.Value, Reason: "must hold strings, numbers, bools or times"}
 			}
 		}
+		return nil
+	}
+	// Check if value is comparable (string, number, bool or time)
+	if cond.Value != nil {
+		if _, ok := indexValueWidth(reflect.TypeOf(cond.Value)); !ok {
+			return InvalidQueryError{Field: "Condition.Value", Value: cond.Value, Reason: "must be a string, number, bool or time"}
+		}
 	}
 	return nil
 }
 
-// getCandidateKeysTx returns keys that match all conditions using the provided tx
-func (s *Store[T]) getCandidateKeysTx(tx *bbolt.Tx, conditions []Condition, maxKeys int) []string {
+// getCandidateKeys returns keys that match all conditions using the provided tx
+func (v *StoreView[T]) getCandidateKeys(conditions []Condition, maxKeys int) []string {
 	if len(conditions) == 0 {
-		return s.getAllKeysTx(tx, maxKeys)
+		return v.getAllKeys(maxKeys)
+	}
+
+	// Answer the conditions covered by a composite index with a single walk over it
+	var compositeKeys []string
+	composite, useComposite := v.store.matchComposite(conditions)
+	if useComposite {
+		var remaining []Condition
+		for index, condition := range conditions {
+			if !composite.used[index] {
+				remaining = append(remaining, condition)
+			}
+		}
+		keysMax := 0
+		if len(remaining) == 0 {
+			keysMax = maxKeys
+		}
+		compositeKeys = v.getKeysForComposite(composite, keysMax)
+		if len(remaining) == 0 {
+			return compositeKeys
+		}
+		conditions = remaining
 	}
 
 	// Partition conditions to leverage indexes where possible
 	var indexedConditions []Condition
 	var nonIndexedConditions []Condition
 	for _, condition := range conditions {
-		if _, ok := s.indexFields[condition.Field]; ok && condition.Value != nil {
-			if _, isString := condition.Value.(string); isString {
-				indexedConditions = append(indexedConditions, condition)
-			} else {
-				nonIndexedConditions = append(nonIndexedConditions, condition)
-			}
+		if v.store.indexedCondition(condition) {
+			indexedConditions = append(indexedConditions, condition)
 		} else {
 			nonIndexedConditions = append(nonIndexedConditions, condition)
 		}
 	}
 
 	// Get key sets from indexed conditions, starting with the shortest
-	var indexedKeys []string
+	indexedKeys := compositeKeys
 	if len(indexedConditions) > 0 {
 		var conditionSizes []condWithSize
 		for _, condition := range indexedConditions {
-			size := s.countKeysForConditionTx(tx, condition, maxKeys)
+			size := v.countKeysForCondition(condition, maxKeys)
 			conditionSizes = append(conditionSizes, condWithSize{condition, size})
 		}
 		// Sort by size ascending
@@ -130,33 +256,39 @@ func (s *Store[T]) getCandidateKeysTx(tx *bbolt.Tx, conditions []Condition, maxK
 		// Primary is the smallest
 		primaryCondition := conditionSizes[0].cond
 		keysMax := 0
-		if len(indexedConditions) == 1 && len(nonIndexedConditions) == 0 {
+		if len(indexedConditions) == 1 && len(nonIndexedConditions) == 0 && !useComposite {
 			keysMax = maxKeys
 		}
-		indexedKeys = s.getKeysForConditionTx(tx, primaryCondition, keysMax)
+		primaryKeys := v.getKeysForCondition(primaryCondition, keysMax)
+		if useComposite {
+			indexedKeys = intersectSlices(indexedKeys, primaryKeys)
+		} else {
+			indexedKeys = primaryKeys
+		}
 		// Intersect others into primary
 		for index := 1; index < len(conditionSizes); index++ {
-			otherConditionKeys := s.getKeysForConditionTx(tx, conditionSizes[index].cond, 0)
+			otherConditionKeys := v.getKeysForCondition(conditionSizes[index].cond, 0)
 			indexedKeys = intersectSlices(indexedKeys, otherConditionKeys)
 		}
 	}
+	hasIndexedKeys := len(indexedConditions) > 0 || useComposite
 
 	// Get keys from non-indexed conditions via single scan
 	var nonIndexedKeys []string
 	if len(nonIndexedConditions) > 0 {
 		// If we have indexed keys, scan only those; otherwise scan all
 		var candidates []string
-		if len(indexedConditions) > 0 {
+		if hasIndexedKeys {
 			candidates = indexedKeys
 		}
-		nonIndexedKeys = s.scanForConditionsTx(tx, nonIndexedConditions, candidates, maxKeys)
+		nonIndexedKeys = v.scanForConditions(nonIndexedConditions, candidates, maxKeys)
 	}
 
 	// Intersect with non-indexed
 	if len(nonIndexedConditions) == 0 {
 		return indexedKeys
 	}
-	if len(indexedConditions) == 0 {
+	if !hasIndexedKeys {
 		return nonIndexedKeys
 	}
 	// Intersect the two
@@ -173,34 +305,38 @@ func (s *Store[T]) getCandidateKeysTx(tx *bbolt.Tx, conditions []Condition, maxK
 	return result
 }
 
-// getKeysForConditionTx returns keys that match the condition, sorted
-func (s *Store[T]) getKeysForConditionTx(tx *bbolt.Tx, condition Condition, maxKeys int) []string {
+// getKeysForCondition returns keys that match the condition, sorted
+func (v *StoreView[T]) getKeysForCondition(condition Condition, maxKeys int) []string {
+	if condition.Operator == ContainsAny || condition.Operator == ContainsAll || condition.Operator == In {
+		return v.getKeysForElements(condition, maxKeys)
+	}
 	var keys []string
-	_, indexed := s.indexFields[condition.Field]
-	valueString, isString := condition.Value.(string)
-	if !indexed || !isString {
+	valueString, indexed := v.store.encodeConditionValue(condition)
+	if !indexed {
 		// This should not happen, as we separate indexed and non-indexed
 		return keys
 	}
 
 	// Use index
-	indexBucketName := string(s.bucket) + "_index_" + condition.Field
-	indexBucket := tx.Bucket([]byte(indexBucketName))
-	if indexBucket == nil {
-		return keys
-	}
-	cursor := indexBucket.Cursor()
+	index, _ := v.store.conditionIndex(condition)
+	indexBucketName := string(v.store.bucket) + "_index_" + index
+	cursor := v.snapshot.cursor([]byte(indexBucketName))
+	layout := v.store.indexLayout(index)
 	var keyBytes []byte
 	switch condition.Operator {
-	case Equals:
+	case Equals, Contains, HasPrefix:
+		// Equal values are followed by the separator, while prefixes may continue with more text
 		prefix := valueString + "\x00"
+		if condition.Operator == HasPrefix {
+			prefix = valueString
+		}
 		for keyBytes, _ = cursor.Seek([]byte(prefix)); keyBytes != nil && bytes.HasPrefix(keyBytes, []byte(prefix)); keyBytes, _ = cursor.Next() {
 			if maxKeys > 0 && len(keys) >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				key := string(parts[1])
+			_, entryKey, ok := layout.split(keyBytes)
+			if ok {
+				key := string(entryKey)
 				keys = append(keys, key)
 			}
 		}
@@ -215,11 +351,11 @@ func (s *Store[T]) getKeysForConditionTx(tx *bbolt.Tx, condition Condition, maxK
 			if maxKeys > 0 && len(keys) >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				value := string(parts[0])
+			valueBytes, entryKey, ok := layout.split(keyBytes)
+			if ok {
+				value := string(valueBytes)
 				if value > valueString {
-					key := string(parts[1])
+					key := string(entryKey)
 					keys = append(keys, key)
 				} else {
 					break
@@ -232,11 +368,11 @@ func (s *Store[T]) getKeysForConditionTx(tx *bbolt.Tx, condition Condition, maxK
 			if maxKeys > 0 && len(keys) >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				value := string(parts[0])
+			valueBytes, entryKey, ok := layout.split(keyBytes)
+			if ok {
+				value := string(valueBytes)
 				if value >= valueString {
-					key := string(parts[1])
+					key := string(entryKey)
 					keys = append(keys, key)
 				} else {
 					break
@@ -248,11 +384,11 @@ func (s *Store[T]) getKeysForConditionTx(tx *bbolt.Tx, condition Condition, maxK
 			if maxKeys > 0 && len(keys) >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				value := string(parts[0])
+			valueBytes, entryKey, ok := layout.split(keyBytes)
+			if ok {
+				value := string(valueBytes)
 				if value < valueString {
-					key := string(parts[1])
+					key := string(entryKey)
 					keys = append(keys, key)
 				} else {
 					break
@@ -264,11 +400,11 @@ func (s *Store[T]) getKeysForConditionTx(tx *bbolt.Tx, condition Condition, maxK
 			if maxKeys > 0 && len(keys) >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				value := string(parts[0])
+			valueBytes, entryKey, ok := layout.split(keyBytes)
+			if ok {
+				value := string(valueBytes)
 				if value <= valueString {
-					key := string(parts[1])
+					key := string(entryKey)
 					keys = append(keys, key)
 				} else {
 					break
@@ -280,25 +416,28 @@ func (s *Store[T]) getKeysForConditionTx(tx *bbolt.Tx, condition Condition, maxK
 	return keys
 }
 
-// countKeysForConditionTx returns the count of keys matching the condition
-func (s *Store[T]) countKeysForConditionTx(tx *bbolt.Tx, condition Condition, maxKeys int) int {
+// countKeysForCondition returns the count of keys matching the condition
+func (v *StoreView[T]) countKeysForCondition(condition Condition, maxKeys int) int {
+	if condition.Operator == ContainsAny || condition.Operator == ContainsAll || condition.Operator == In {
+		return len(v.getKeysForElements(condition, maxKeys))
+	}
 	var count int
-	_, indexed := s.indexFields[condition.Field]
-	valueString, isString := condition.Value.(string)
-	if !indexed || !isString {
+	valueString, indexed := v.store.encodeConditionValue(condition)
+	if !indexed {
 		return 0
 	}
 
-	indexBucketName := string(s.bucket) + "_index_" + condition.Field
-	indexBucket := tx.Bucket([]byte(indexBucketName))
-	if indexBucket == nil {
-		return 0
-	}
-	cursor := indexBucket.Cursor()
+	index, _ := v.store.conditionIndex(condition)
+	indexBucketName := string(v.store.bucket) + "_index_" + index
+	cursor := v.snapshot.cursor([]byte(indexBucketName))
+	layout := v.store.indexLayout(index)
 	var keyBytes []byte
 	switch condition.Operator {
-	case Equals:
+	case Equals, Contains, HasPrefix:
 		prefix := valueString + "\x00"
+		if condition.Operator == HasPrefix {
+			prefix = valueString
+		}
 		for keyBytes, _ = cursor.Seek([]byte(prefix)); keyBytes != nil && bytes.HasPrefix(keyBytes, []byte(prefix)); keyBytes, _ = cursor.Next() {
 			count++
 			if maxKeys > 0 && count >= maxKeys {
@@ -317,9 +456,9 @@ func (s *Store[T]) countKeysForConditionTx(tx *bbolt.Tx, condition Condition, ma
 			if maxKeys > 0 && count >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				value := string(parts[0])
+			valueBytes, _, ok := layout.split(keyBytes)
+			if ok {
+				value := string(valueBytes)
 				if value <= valueString {
 					break
 				}
@@ -332,9 +471,9 @@ func (s *Store[T]) countKeysForConditionTx(tx *bbolt.Tx, condition Condition, ma
 			if maxKeys > 0 && count >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				value := string(parts[0])
+			valueBytes, _, ok := layout.split(keyBytes)
+			if ok {
+				value := string(valueBytes)
 				if value < valueString {
 					break
 				}
@@ -346,9 +485,9 @@ func (s *Store[T]) countKeysForConditionTx(tx *bbolt.Tx, condition Condition, ma
 			if maxKeys > 0 && count >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				value := string(parts[0])
+			valueBytes, _, ok := layout.split(keyBytes)
+			if ok {
+				value := string(valueBytes)
 				if value >= valueString {
 					break
 				}
@@ -360,9 +499,9 @@ func (s *Store[T]) countKeysForConditionTx(tx *bbolt.Tx, condition Condition, ma
 			if maxKeys > 0 && count >= maxKeys {
 				break
 			}
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				value := string(parts[0])
+			valueBytes, _, ok := layout.split(keyBytes)
+			if ok {
+				value := string(valueBytes)
 				if value > valueString {
 					break
 				}
@@ -375,11 +514,30 @@ func (s *Store[T]) countKeysForConditionTx(tx *bbolt.Tx, condition Condition, ma
 // matchesCondition checks if the item matches the condition
 func (s *Store[T]) matchesCondition(item T, condition Condition) bool {
 	itemValue := reflect.ValueOf(item)
-	if fieldIndex, ok := s.fieldMap[condition.Field]; ok {
-		fieldValue := itemValue.Field(fieldIndex)
+	if fieldIndex, ok := s.resolveField(condition.Field); ok {
+		// Fields left without a value by a nil pointer match no condition
+		fieldValue, ok := s.fieldValue(itemValue, fieldIndex)
+		if !ok {
+			return false
+		}
+		// Collated fields compare their normalised strings
+		if collation, ok := s.collations[fieldIndex]; ok {
+			fieldValue = reflect.ValueOf(collation.applyValue(fieldValue.Interface()))
+			condition.Value = collation.applyValue(condition.Value)
+		}
 		switch condition.Operator {
 		case Equals:
-			return reflect.DeepEqual(fieldValue.Interface(), condition.Value)
+			return equalValues(fieldValue.Interface(), condition.Value)
+		case NotEquals:
+			return !equalValues(fieldValue.Interface(), condition.Value)
+		case In, NotIn:
+			elements, _ := conditionElements(condition.Value)
+			for _, element := range elements {
+				if equalValues(fieldValue.Interface(), element) {
+					return condition.Operator == In
+				}
+			}
+			return condition.Operator == NotIn
 		case GreaterThan:
 			return compare(fieldValue.Interface(), condition.Value) > 0
 		case LessThan:
@@ -388,6 +546,16 @@ func (s *Store[T]) matchesCondition(item T, condition Condition) bool {
 			return compare(fieldValue.Interface(), condition.Value) >= 0
 		case LessThanOrEqual:
 			return compare(fieldValue.Interface(), condition.Value) <= 0
+		case Contains:
+			if fieldValue.Kind() == reflect.String {
+				return matchesString(fieldValue, condition)
+			}
+			return containsElements(fieldValue, []interface{}{condition.Value}, true)
+		case HasPrefix, Like, Regexp:
+			return matchesString(fieldValue, condition)
+		case ContainsAny, ContainsAll:
+			elements, _ := conditionElements(condition.Value)
+			return containsElements(fieldValue, elements, condition.Operator == ContainsAll)
 		}
 	}
 	return false
@@ -395,38 +563,79 @@ func (s *Store[T]) matchesCondition(item T, condition Condition) bool {
 
 // compare compares two values, assumes comparable types
 func compare(a, b interface{}) int {
-	switch va := a.(type) {
-	case string:
-		if vb, ok := b.(string); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
-			}
-			return 0
+	comparison, _ := compareValues(a, b)
+	return comparison // not comparable, treat as equal
+}
+
+// compareValues compares two strings, numbers, booleans or times, reporting false when the values are not comparable.
+// Numbers of different types are compared by value.
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if timeA, ok := a.(time.Time); ok {
+		if timeB, ok := b.(time.Time); ok {
+			return timeA.Compare(timeB), true
+		}
+		return 0, false
+	}
+	valueA := reflect.ValueOf(a)
+	valueB := reflect.ValueOf(b)
+	switch valueA.Kind() {
+	case reflect.String:
+		if valueB.Kind() == reflect.String {
+			return strings.Compare(valueA.String(), valueB.String()), true
 		}
-	case int:
-		if vb, ok := b.(int); ok {
-			if va < vb {
-				return -1
-			} else if va > vb {
-				return 1
+	case reflect.Bool:
+		if valueB.Kind() == reflect.Bool {
+			boolA, boolB := valueA.Bool(), valueB.Bool()
+			if boolA == boolB {
+				return 0, true
+			} else if boolB {
+				return -1, true
 			}
-			return 0
+			return 1, true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if signedB, ok := signedValue(valueB); ok {
+			return compareOrdered(valueA.Int(), signedB), true
+		}
+		if _, ok := unsignedValue(valueB); ok {
+			// Unsigned values beyond the signed range are always greater
+			return -1, true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if unsignedB, ok := unsignedValue(valueB); ok {
+			return compareOrdered(valueA.Uint(), unsignedB), true
+		}
+		if _, ok := signedValue(valueB); ok {
+			// Negative values are always smaller
+			return 1, true
+		}
+	}
+	// Compare any remaining numeric combination, including floats, by value
+	if floatA, ok := floatValue(valueA); ok {
+		if floatB, ok := floatValue(valueB); ok {
+			return compareOrdered(floatA, floatB), true
 		}
 	}
-	return 0 // not comparable, treat as equal
+	return 0, false
 }
 
-// getAllKeysTx returns all keys in the bucket, sorted, up to maxKeys if >0
-func (s *Store[T]) getAllKeysTx(tx *bbolt.Tx, maxKeys int) []string {
-	var keys []string
-	bucket := tx.Bucket(s.bucket)
-	if bucket == nil {
-		// Bucket not found, return empty
-		return keys
+// compareOrdered compares two ordered values
+func compareOrdered[V int64 | uint64 | float64](a, b V) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
 	}
-	cursor := bucket.Cursor()
+	return 0
+}
+
+// getAllKeys returns all keys in the bucket, sorted, up to maxKeys if >0
+func (v *StoreView[T]) getAllKeys(maxKeys int) []string {
+	var keys []string
+	cursor := v.snapshot.cursor(v.store.bucket)
 	for keyBytes, _ := cursor.First(); keyBytes != nil; keyBytes, _ = cursor.Next() {
 		if maxKeys > 0 && len(keys) >= maxKeys {
 			break
@@ -436,116 +645,89 @@ func (s *Store[T]) getAllKeysTx(tx *bbolt.Tx, maxKeys int) []string {
 	return keys
 }
 
-// countAllKeysTx returns the count of all keys in the bucket
-func (s *Store[T]) countAllKeysTx(tx *bbolt.Tx) int {
-	count := 0
-	bucket := tx.Bucket(s.bucket)
-	if bucket == nil {
-		// Bucket not found, return 0
-		return count
+// getKeysWithPrefix returns the keys starting with the prefix, sorted, up to maxKeys if >0
+func (v *StoreView[T]) getKeysWithPrefix(prefix string, maxKeys int) []string {
+	var keys []string
+	cursor := v.snapshot.cursor(v.store.bucket)
+	for keyBytes, _ := cursor.Seek([]byte(prefix)); keyBytes != nil && bytes.HasPrefix(keyBytes, []byte(prefix)); keyBytes, _ = cursor.Next() {
+		if maxKeys > 0 && len(keys) >= maxKeys {
+			break
+		}
+		keys = append(keys, string(keyBytes))
 	}
-	cursor := bucket.Cursor()
-	for keyBytes, _ := cursor.First(); keyBytes != nil; keyBytes, _ = cursor.Next() {
-		count++
+	return keys
+}
+
+// filterKeyPrefix keeps the keys starting with the prefix
+func filterKeyPrefix(keys []string, prefix string) []string {
+	filtered := keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			filtered = append(filtered, key)
+		}
 	}
-	return count
+	return filtered
 }
 
-// getKeysFromIndexTx returns all keys sorted by the index
-func (s *Store[T]) getKeysFromIndexTx(tx *bbolt.Tx, index string, sorting Sorting, maxKeys int) []string {
+// countAllKeys returns the count of all keys in the bucket
+func (v *StoreView[T]) countAllKeys() int {
+	return v.snapshot.count(v.store.bucket)
+}
+
+// getKeysFromIndex returns all keys sorted by the index
+func (v *StoreView[T]) getKeysFromIndex(index string, sorting Sorting, maxKeys int) []string {
+	index, _ = v.store.resolveIndex(index)
 	var keys []string
-	indexBucketName := string(s.bucket) + "_index_" + index
-	indexBucket := tx.Bucket([]byte(indexBucketName))
-	if indexBucket == nil {
-		return keys
-	}
-	cursor := indexBucket.Cursor()
+	indexBucketName := string(v.store.bucket) + "_index_" + index
+	cursor := v.snapshot.cursor([]byte(indexBucketName))
+	layout := v.store.indexLayout(index)
 	var keyBytes []byte
 	if sorting == Descending {
 		for keyBytes, _ = cursor.Last(); keyBytes != nil && (maxKeys == 0 || len(keys) < maxKeys); keyBytes, _ = cursor.Prev() {
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				key := string(parts[1])
-				keys = append(keys, key)
+			if _, key, ok := layout.split(keyBytes); ok {
+				keys = append(keys, string(key))
 			}
 		}
 	} else {
 		for keyBytes, _ = cursor.First(); keyBytes != nil && (maxKeys == 0 || len(keys) < maxKeys); keyBytes, _ = cursor.Next() {
-			parts := bytes.SplitN(keyBytes, []byte("\x00"), 2)
-			if len(parts) == 2 {
-				key := string(parts[1])
-				keys = append(keys, key)
+			if _, key, ok := layout.split(keyBytes); ok {
+				keys = append(keys, string(key))
 			}
 		}
 	}
 	return keys
 }
 
-// countKeysFromIndexTx returns the count of keys in the index
-func (s *Store[T]) countKeysFromIndexTx(tx *bbolt.Tx, index string) int {
-	count := 0
-	indexBucketName := string(s.bucket) + "_index_" + index
-	indexBucket := tx.Bucket([]byte(indexBucketName))
-	if indexBucket == nil {
-		return count
-	}
-	cursor := indexBucket.Cursor()
-	for keyBytes, _ := cursor.First(); keyBytes != nil; keyBytes, _ = cursor.Next() {
-		count++
-	}
-	return count
+// countKeysFromIndex returns the count of keys in the index
+func (v *StoreView[T]) countKeysFromIndex(index string) int {
+	index, _ = v.store.resolveIndex(index)
+	indexBucketName := string(v.store.bucket) + "_index_" + index
+	return v.snapshot.count([]byte(indexBucketName))
 }
 
-// countUniqueValuesFromIndexTx returns the count of unique values in the index
-func (s *Store[T]) countUniqueValuesFromIndexTx(tx *bbolt.Tx, index string) int {
-	indexBucketName := string(s.bucket) + "_index_" + index
-	indexBucket := tx.Bucket([]byte(indexBucketName))
-	if indexBucket == nil {
-		return 0
-	}
-
-	cursor := indexBucket.Cursor()
-	var count int
-	var lastValue []byte
-
-	for keyBytes, _ := cursor.First(); keyBytes != nil; keyBytes, _ = cursor.Next() {
-		// Find the position of the null byte separator
-		nullPos := bytes.IndexByte(keyBytes, '\x00')
-		if nullPos == -1 {
-			continue // Malformed key, skip
-		}
-
-		// Extract the value part (everything before \x00)
-		currentValue := keyBytes[:nullPos]
-
-		// If this is the first value or different from the last one, increment count
-		if lastValue == nil || !bytes.Equal(currentValue, lastValue) {
-			count++
-			// Allocate a copy for comparison with next iteration
-			lastValue = make([]byte, len(currentValue))
-			copy(lastValue, currentValue)
-		}
+// scanForConditions scans records and returns keys matching all conditions
+// If candidates is not nil, only scans those keys; otherwise scans all.
+// Limits to maxKeys if >0.
+func (v *StoreView[T]) scanForConditions(conditions []Condition, candidates []string, maxKeys int) []string {
+	expression := make(andExpression, len(conditions))
+	for index, condition := range conditions {
+		expression[index] = condition
 	}
-
-	return count
+	return v.scanForExpression(expression, candidates, maxKeys)
 }
 
-// scanForConditionsTx scans records and returns keys matching all conditions
+// scanForExpression scans records and returns keys matching the expression
 // If candidates is not nil, only scans those keys; otherwise scans all.
 // Limits to maxKeys if >0.
-func (s *Store[T]) scanForConditionsTx(tx *bbolt.Tx, conditions []Condition, candidates []string, maxKeys int) []string {
+func (v *StoreView[T]) scanForExpression(expression Expression, candidates []string, maxKeys int) []string {
+	expression = prepareExpression(expression)
 	var keys []string
-	bucket := tx.Bucket(s.bucket)
-	if bucket == nil {
-		// Bucket not found, return empty
-		return keys
-	}
 	decoder := msgpack.GetDecoder()
 	defer msgpack.PutDecoder(decoder)
 	if candidates != nil {
 		// Scan only candidate keys
 		for _, key := range candidates {
-			data := bucket.Get([]byte(key))
+			data := v.snapshot.get(v.store.bucket, []byte(key))
 			if data == nil {
 				continue
 			}
@@ -555,14 +737,7 @@ func (s *Store[T]) scanForConditionsTx(tx *bbolt.Tx, conditions []Condition, can
 			if err != nil {
 				continue
 			}
-			matches := true
-			for _, condition := range conditions {
-				if !s.matchesCondition(item, condition) {
-					matches = false
-					break
-				}
-			}
-			if matches {
+			if v.store.matchesExpression(item, expression) {
 				keys = append(keys, key)
 				if maxKeys > 0 && len(keys) >= maxKeys {
 					break
@@ -571,7 +746,7 @@ func (s *Store[T]) scanForConditionsTx(tx *bbolt.Tx, conditions []Condition, can
 		}
 	} else {
 		// Scan all records
-		cursor := bucket.Cursor()
+		cursor := v.snapshot.cursor(v.store.bucket)
 		for keyBytes, valueBytes := cursor.First(); keyBytes != nil; keyBytes, valueBytes = cursor.Next() {
 			var item T
 			decoder.Reset(bytes.NewReader(valueBytes))
@@ -579,14 +754,7 @@ func (s *Store[T]) scanForConditionsTx(tx *bbolt.Tx, conditions []Condition, can
 			if err != nil {
 				continue
 			}
-			matches := true
-			for _, condition := range conditions {
-				if !s.matchesCondition(item, condition) {
-					matches = false
-					break
-				}
-			}
-			if matches {
+			if v.store.matchesExpression(item, expression) {
 				keys = append(keys, string(keyBytes))
 				if maxKeys > 0 && len(keys) >= maxKeys {
 					break
@@ -597,6 +765,29 @@ func (s *Store[T]) scanForConditionsTx(tx *bbolt.Tx, conditions []Condition, can
 	return keys
 }
 
+// countDeleted returns the number of soft-deleted records in the bucket
+func (v *StoreView[T]) countDeleted() int {
+	return v.snapshot.count(tombstoneBucketName(v.store.bucket))
+}
+
+// hasDeleted reports whether any record in the bucket is soft-deleted
+func (v *StoreView[T]) hasDeleted() bool {
+	keyBytes, _ := v.snapshot.cursor(tombstoneBucketName(v.store.bucket)).First()
+	return keyBytes != nil
+}
+
+// filterDeleted removes soft-deleted keys while preserving the order of the remaining keys
+func (v *StoreView[T]) filterDeleted(keys []string) []string {
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	result := keys[:0]
+	for _, key := range keys {
+		if v.snapshot.get(tombstoneBucket, []byte(key)) == nil {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
 // intersectSlices intersects two key slices, returning keys in base that are also in other
 func intersectSlices(base, other []string) []string {
 	baseMap := make(map[string]bool, len(base))
@@ -611,20 +802,3 @@ func intersectSlices(base, other []string) []string {
 	}
 	return result
 }
-
-// sortResults sorts the results by the index field
-func (s *Store[T]) sortResults(results []T, index string, sorting Sorting) {
-	fieldIndex, ok := s.indexFields[index]
-	if !ok {
-		return
-	}
-	sort.Slice(results, func(i, j int) bool {
-		valueA := reflect.ValueOf(results[i]).Field(fieldIndex)
-		valueB := reflect.ValueOf(results[j]).Field(fieldIndex)
-		comparison := compare(valueA.Interface(), valueB.Interface())
-		if sorting == Descending {
-			return comparison > 0
-		}
-		return comparison < 0
-	})
-}
@@ -0,0 +1,272 @@
+package nnut
+
+import (
+	"sort"
+)
+
+// Expression is a boolean combination of conditions, built from conditions with And, Or and Not
+type Expression interface {
+	isExpression()
+}
+
+type andExpression []Expression
+
+type orExpression []Expression
+
+type notExpression struct {
+	expression Expression
+}
+
+func (Condition) isExpression()     {}
+func (andExpression) isExpression() {}
+func (orExpression) isExpression()  {}
+func (notExpression) isExpression() {}
+
+// And matches records matching all of the expressions, or every record when there are none
+func And(expressions ...Expression) Expression {
+	return andExpression(expressions)
+}
+
+// Or matches records matching any of the expressions, or no record when there are none
+func Or(expressions ...Expression) Expression {
+	return orExpression(expressions)
+}
+
+// Not matches records not matching the expression
+func Not(expression Expression) Expression {
+	return notExpression{expression: expression}
+}
+
+// hasFilter reports whether a query selects records through conditions or an expression
+func (query *Query) hasFilter() bool {
+	return len(query.Conditions) > 0 || query.Where != nil
+}
+
+// validateExpression validates the conditions of an expression
+func (s *Store[T]) validateExpression(expression Expression) error {
+	switch expression := expression.(type) {
+	case Condition:
+		return s.validateCondition(expression)
+	case andExpression:
+		for _, child := range expression {
+			if err := s.validateExpression(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case orExpression:
+		for _, child := range expression {
+			if err := s.validateExpression(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case notExpression:
+		return s.validateExpression(expression.expression)
+	}
+	return InvalidQueryError{Field: "Where", Value: expression, Reason: "must be a condition or be built with And, Or and Not"}
+}
+
+// getFilterKeys returns the keys of the records matching the conditions and expression of a query, sorted
+func (v *StoreView[T]) getFilterKeys(query *Query, maxKeys int) []string {
+	if query.Where == nil {
+		return v.getCandidateKeys(query.Conditions, maxKeys)
+	}
+	return v.getExpressionKeys(v.store.queryExpression(query), maxKeys)
+}
+
+// queryExpression combines the conditions and expression of a query into a single expression
+func (s *Store[T]) queryExpression(query *Query) Expression {
+	if len(query.Conditions) == 0 && query.Where != nil {
+		return query.Where
+	}
+	expression := make(andExpression, 0, len(query.Conditions)+1)
+	for _, condition := range query.Conditions {
+		expression = append(expression, condition)
+	}
+	if query.Where != nil {
+		expression = append(expression, query.Where)
+	}
+	return expression
+}
+
+// getExpressionKeys returns the keys of the records matching an expression, sorted. Index lookups narrow down the
+// records first, and only records the indexes cannot decide on are decoded and matched.
+func (v *StoreView[T]) getExpressionKeys(expression Expression, maxKeys int) []string {
+	keys, exact, indexed := v.indexExpressionKeys(expression)
+	if indexed && exact {
+		if maxKeys > 0 && len(keys) > maxKeys {
+			keys = keys[:maxKeys]
+		}
+		return keys
+	}
+	if !indexed {
+		keys = nil
+	} else if keys == nil {
+		keys = []string{}
+	}
+	return v.scanForExpression(expression, keys, maxKeys)
+}
+
+// indexExpressionKeys returns the sorted keys an expression can be narrowed down to through indexes, reporting
+// whether the keys match the expression exactly and false when the indexes cannot narrow it down
+func (v *StoreView[T]) indexExpressionKeys(expression Expression) ([]string, bool, bool) {
+	switch expression := expression.(type) {
+	case Condition:
+		if !v.store.indexedCondition(expression) {
+			return nil, false, false
+		}
+		return v.getKeysForCondition(expression, 0), true, true
+	case andExpression:
+		// Conditions side by side are answered together, so that composite indexes can be used
+		var conditions []Condition
+		var others []Expression
+		for _, child := range expression {
+			if condition, ok := child.(Condition); ok {
+				conditions = append(conditions, condition)
+			} else {
+				others = append(others, child)
+			}
+		}
+		var keys []string
+		exact, indexed := true, false
+		if len(conditions) > 0 {
+			if v.store.indexedConditions(conditions) {
+				// Conditions not answered by an index are matched against the indexed records only
+				keys, indexed = v.getCandidateKeys(conditions, 0), true
+			} else {
+				exact = false
+			}
+		}
+		for _, child := range others {
+			childKeys, childExact, childIndexed := v.indexExpressionKeys(child)
+			if !childIndexed {
+				exact = false
+				continue
+			}
+			if indexed {
+				keys = intersectSlices(keys, childKeys)
+			} else {
+				keys, indexed = childKeys, true
+			}
+			exact = exact && childExact
+		}
+		if !indexed && len(expression) == 0 {
+			return v.getAllKeys(0), true, true
+		}
+		return keys, exact, indexed
+	case orExpression:
+		// Every branch must be narrowed down, as a single scanned branch requires scanning all records
+		var keys []string
+		exact := true
+		for _, child := range expression {
+			childKeys, childExact, childIndexed := v.indexExpressionKeys(child)
+			if !childIndexed {
+				return nil, false, false
+			}
+			keys = unionSlices(keys, childKeys)
+			exact = exact && childExact
+		}
+		return keys, exact, true
+	case notExpression:
+		// Records outside an exact match are found from the keys alone, without decoding them
+		childKeys, childExact, childIndexed := v.indexExpressionKeys(expression.expression)
+		if !childIndexed || !childExact {
+			return nil, false, false
+		}
+		return differenceSlices(v.getAllKeys(0), childKeys), true, true
+	}
+	return nil, false, false
+}
+
+// indexedConditions reports whether any of the conditions is answered through an index
+func (s *Store[T]) indexedConditions(conditions []Condition) bool {
+	if _, ok := s.matchComposite(conditions); ok {
+		return true
+	}
+	for _, condition := range conditions {
+		if s.indexedCondition(condition) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesExpression checks if the item matches the expression
+func (s *Store[T]) matchesExpression(item T, expression Expression) bool {
+	switch expression := expression.(type) {
+	case Condition:
+		return s.matchesCondition(item, expression)
+	case andExpression:
+		for _, child := range expression {
+			if !s.matchesExpression(item, child) {
+				return false
+			}
+		}
+		return true
+	case orExpression:
+		for _, child := range expression {
+			if s.matchesExpression(item, child) {
+				return true
+			}
+		}
+		return false
+	case notExpression:
+		return !s.matchesExpression(item, expression.expression)
+	}
+	return false
+}
+
+// prepareExpression compiles the patterns of the Regexp conditions of an expression once, before it is matched
+// against many records
+func prepareExpression(expression Expression) Expression {
+	switch expression := expression.(type) {
+	case Condition:
+		return prepareConditions([]Condition{expression})[0]
+	case andExpression:
+		prepared := make(andExpression, len(expression))
+		for index, child := range expression {
+			prepared[index] = prepareExpression(child)
+		}
+		return prepared
+	case orExpression:
+		prepared := make(orExpression, len(expression))
+		for index, child := range expression {
+			prepared[index] = prepareExpression(child)
+		}
+		return prepared
+	case notExpression:
+		return notExpression{expression: prepareExpression(expression.expression)}
+	}
+	return expression
+}
+
+// unionSlices merges two sorted key slices into the sorted keys found in either
+func unionSlices(a, b []string) []string {
+	result := make([]string, 0, len(a)+len(b))
+	result = append(result, a...)
+	result = append(result, b...)
+	sort.Strings(result)
+	unique := result[:0]
+	for _, key := range result {
+		if len(unique) == 0 || key != unique[len(unique)-1] {
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
+
+// differenceSlices returns the keys in base that are not in other, preserving the order of base
+func differenceSlices(base, other []string) []string {
+	otherMap := make(map[string]bool, len(other))
+	for _, key := range other {
+		otherMap[key] = true
+	}
+	var result []string
+	for _, key := range base {
+		if !otherMap[key] {
+			result = append(result, key)
+		}
+	}
+	return result
+}
@@ -0,0 +1,193 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQueryExpressions(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	users := []TestUser{
+		{UUID: "1", Name: "ron", Email: "ron@example.com", Age: 30},
+		{UUID: "2", Name: "rosa", Email: "rosa@example.org", Age: 25},
+		{UUID: "3", Name: "ana", Email: "ana@example.com", Age: 40},
+		{UUID: "4", Name: "bob", Email: "ron@example.org", Age: 30},
+		{UUID: "5", Name: "eve", Email: "eve@example.net", Age: 50},
+	}
+	err = store.PutBatch(context.Background(), users[:3])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), users[3:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	ids := func(results []TestUser) string {
+		var ids string
+		for _, result := range results {
+			ids += result.UUID
+		}
+		return ids
+	}
+
+	org := Condition{Field: "Email", Value: "%.org", Operator: Like}
+	for _, test := range []struct {
+		where    Expression
+		indexed  bool
+		exact    bool
+		expected string
+	}{
+		{Or(Condition{Field: "Email", Value: "ron@example.com"}, Condition{Field: "Name", Value: "ron"}), true, true, "1"},
+		{Or(Condition{Field: "Email", Value: "ron@example.org"}, Condition{Field: "Name", Value: "ron"}), true, true, "14"},
+		{Or(Condition{Field: "Age", Value: 40, Operator: GreaterThanOrEqual}, Condition{Field: "Name", Value: "rosa"}), true, true, "235"},
+		{Not(Condition{Field: "Age", Value: 30}), true, true, "235"},
+		{Not(Or(Condition{Field: "Age", Value: 30}, Condition{Field: "Name", Value: "eve"})), true, true, "23"},
+		{And(Condition{Field: "Age", Value: 30}, Not(Condition{Field: "Name", Value: "bob"})), true, true, "1"},
+		{And(Condition{Field: "Age", Value: 30}, org), true, true, "4"},
+		{Or(Condition{Field: "Age", Value: 30}, org), false, false, "124"},
+		{Not(org), false, false, "135"},
+		{And(Condition{Field: "Age", Value: 30, Operator: GreaterThan}, Or(Condition{Field: "Name", Value: "ana"}, org)), true, false, "3"},
+		{Or(And(Condition{Field: "Age", Value: 30}, Condition{Field: "Name", Value: "ron"}), And(Condition{Field: "Age", Value: 25}, org)), true, true, "12"},
+		{And(), true, true, "12345"},
+		{Or(), true, true, ""},
+		{Condition{Field: "Name", Value: "eve"}, true, true, "5"},
+	} {
+		err := db.view(context.Background(), store.bucket, nil, func(snapshot *Snapshot) error {
+			_, exact, indexed := store.In(snapshot).indexExpressionKeys(test.where)
+			if indexed != test.indexed || exact != test.exact {
+				t.Fatalf("Expected %+v to be indexed %v and exact %v, got %v and %v", test.where, test.indexed, test.exact, indexed, exact)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to view: %v", err)
+		}
+
+		results, err := store.GetQuery(context.Background(), &Query{Where: test.where})
+		if err != nil {
+			t.Fatalf("Failed to query %+v: %v", test.where, err)
+		}
+		if ids(results) != test.expected {
+			t.Fatalf("Expected %s for %+v, got %s", test.expected, test.where, ids(results))
+		}
+		count, err := store.CountQuery(context.Background(), &Query{Where: test.where})
+		if err != nil {
+			t.Fatalf("Failed to count: %v", err)
+		}
+		if count != len(test.expected) {
+			t.Fatalf("Expected count %d for %+v, got %d", len(test.expected), test.where, count)
+		}
+	}
+
+	// Conditions and the expression must both match, with sorting and pagination applied afterwards
+	results, err := store.GetQuery(context.Background(), &Query{
+		Conditions: []Condition{{Field: "Age", Value: 50, Operator: LessThan}},
+		Where:      Or(Condition{Field: "Name", Value: "ro", Operator: HasPrefix}, Condition{Field: "Age", Value: 40}),
+		Index:      "age",
+		Sort:       Descending,
+	})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "312" {
+		t.Fatalf("Expected 312, got %s", ids(results))
+	}
+	results, err = store.GetQuery(context.Background(), &Query{Where: Not(Condition{Field: "Name", Value: "ana"}), Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "24" {
+		t.Fatalf("Expected 24, got %s", ids(results))
+	}
+
+	// Deleting through an expression removes exactly the matching records
+	deleted, err := store.DeleteQuery(context.Background(), &Query{Where: Or(Condition{Field: "Name", Value: "ron"}, org)})
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if deleted != 3 {
+		t.Fatalf("Expected 3 deleted records, got %d", deleted)
+	}
+	results, err = store.GetQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "35" {
+		t.Fatalf("Expected 35, got %s", ids(results))
+	}
+
+	// Invalid conditions are reported from anywhere in the expression
+	for _, where := range []Expression{
+		And(Condition{Field: "Missing", Value: 1}),
+		Or(Condition{Field: "Name", Value: "ana"}, Not(Condition{Field: "Age", Value: []int{1}})),
+		Or(Condition{Field: "Name", Value: "ana"}, nil),
+	} {
+		_, err := store.GetQuery(context.Background(), &Query{Where: where})
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Fatalf("Expected InvalidQueryError for %+v, got %v", where, err)
+		}
+	}
+}
+
+func TestQueryExpressionSoftDelete(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestUser](db, "users", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.PutBatch(context.Background(), []TestUser{
+		{UUID: "1", Name: "ron", Age: 30},
+		{UUID: "2", Name: "rosa", Age: 25},
+		{UUID: "3", Name: "ana", Age: 40},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Delete(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	// Negations built from the keys leave soft-deleted records out
+	where := Not(Condition{Field: "Name", Value: "rosa"})
+	count, err := store.CountQuery(context.Background(), &Query{Where: where})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 record, got %d", count)
+	}
+	count, err = store.CountQuery(context.Background(), &Query{Where: where, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 records, got %d", count)
+	}
+}
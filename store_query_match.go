@@ -0,0 +1,104 @@
+package nnut
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// equalValues reports whether two values are equal, comparing numbers of different types by value
+func equalValues(a, b interface{}) bool {
+	if comparison, ok := compareValues(a, b); ok {
+		return comparison == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// matchLike reports whether a string matches a pattern in which % matches any run of characters and _ matches a
+// single character
+func matchLike(value, pattern string) bool {
+	text := []rune(value)
+	wildcards := []rune(pattern)
+	// Backtrack to the last % when a character does not match
+	position, patternPosition := 0, 0
+	backtrackPosition, backtrackPattern := -1, -1
+	for position < len(text) {
+		if patternPosition < len(wildcards) && (wildcards[patternPosition] == '_' || wildcards[patternPosition] == text[position]) {
+			position++
+			patternPosition++
+		} else if patternPosition < len(wildcards) && wildcards[patternPosition] == '%' {
+			backtrackPattern = patternPosition
+			backtrackPosition = position
+			patternPosition++
+		} else if backtrackPattern != -1 {
+			backtrackPosition++
+			position = backtrackPosition
+			patternPosition = backtrackPattern + 1
+		} else {
+			return false
+		}
+	}
+	for patternPosition < len(wildcards) && wildcards[patternPosition] == '%' {
+		patternPosition++
+	}
+	return patternPosition == len(wildcards)
+}
+
+// conditionPattern returns the regular expression of a Regexp condition, given either compiled or as a string
+func conditionPattern(value interface{}) (*regexp.Regexp, error) {
+	switch pattern := value.(type) {
+	case *regexp.Regexp:
+		return pattern, nil
+	case string:
+		return regexp.Compile(pattern)
+	}
+	return nil, InvalidQueryError{Field: "Condition.Value", Value: value, Reason: "must be a regular expression"}
+}
+
+// prepareConditions compiles the patterns of Regexp conditions once, before they are matched against many records
+func prepareConditions(conditions []Condition) []Condition {
+	var prepared []Condition
+	for index, condition := range conditions {
+		if condition.Operator != Regexp {
+			continue
+		}
+		if _, ok := condition.Value.(string); !ok {
+			continue
+		}
+		if pattern, err := conditionPattern(condition.Value); err == nil {
+			if prepared == nil {
+				prepared = append([]Condition(nil), conditions...)
+			}
+			prepared[index].Value = pattern
+		}
+	}
+	if prepared == nil {
+		return conditions
+	}
+	return prepared
+}
+
+// matchesString reports whether a string field matches a HasPrefix, Contains, Like or Regexp condition
+func matchesString(fieldValue reflect.Value, condition Condition) bool {
+	if fieldValue.Kind() != reflect.String {
+		return false
+	}
+	value := fieldValue.String()
+	if condition.Operator == Regexp {
+		pattern, err := conditionPattern(condition.Value)
+		return err == nil && pattern.MatchString(value)
+	}
+	text, ok := condition.Value.(string)
+	if !ok {
+		return false
+	}
+	switch condition.Operator {
+	case HasPrefix:
+		return strings.HasPrefix(value, text)
+	case Contains:
+		return strings.Contains(value, text)
+	case Like:
+		return matchLike(value, text)
+	}
+	return false
+}
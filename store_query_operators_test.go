@@ -0,0 +1,174 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestStringAndSetOperators(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	users := []TestUser{
+		{UUID: "1", Name: "Ron", Email: "ron@example.com", Age: 30},
+		{UUID: "2", Name: "Rosa", Email: "rosa@example.org", Age: 25},
+		{UUID: "3", Name: "Ana", Email: "ana@example.com", Age: 40},
+		{UUID: "4", Name: "Roland", Email: "roland@test.net", Age: 30},
+	}
+	err = store.PutBatch(context.Background(), users[:2])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), users[2:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	ids := func(results []TestUser) string {
+		var ids string
+		for _, result := range results {
+			ids += result.UUID
+		}
+		return ids
+	}
+
+	for _, test := range []struct {
+		condition Condition
+		indexed   bool
+		expected  string
+	}{
+		{Condition{Field: "Name", Value: "Ro", Operator: HasPrefix}, true, "124"},
+		{Condition{Field: "Email", Value: "ro", Operator: HasPrefix}, true, "124"},
+		{Condition{Field: "Name", Value: "", Operator: HasPrefix}, true, "1234"},
+		{Condition{Field: "Email", Value: "example", Operator: Contains}, false, "123"},
+		{Condition{Field: "Email", Value: "%@example._om", Operator: Like}, false, "13"},
+		{Condition{Field: "Name", Value: "R_%a", Operator: Like}, false, "2"},
+		{Condition{Field: "Email", Value: `\.(org|net)$`, Operator: Regexp}, false, "24"},
+		{Condition{Field: "Email", Value: regexp.MustCompile(`^r.*com$`), Operator: Regexp}, false, "1"},
+		{Condition{Field: "Age", Value: 30, Operator: NotEquals}, false, "23"},
+		{Condition{Field: "Age", Value: []int{25, 40}, Operator: In}, true, "23"},
+		{Condition{Field: "Name", Value: []string{"Ana", "Ron", "Zed"}, Operator: In}, true, "13"},
+		{Condition{Field: "Age", Value: []int{25, 40}, Operator: NotIn}, false, "14"},
+		{Condition{Field: "Age", Value: []int{}, Operator: In}, false, ""},
+	} {
+		if store.indexedCondition(test.condition) != test.indexed {
+			t.Fatalf("Expected %+v to be indexed: %v", test.condition, test.indexed)
+		}
+		results, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{test.condition}})
+		if err != nil {
+			t.Fatalf("Failed to query %+v: %v", test.condition, err)
+		}
+		if ids(results) != test.expected {
+			t.Fatalf("Expected %s for %+v, got %s", test.expected, test.condition, ids(results))
+		}
+		count, err := store.CountQuery(context.Background(), &Query{Conditions: []Condition{test.condition}})
+		if err != nil {
+			t.Fatalf("Failed to count: %v", err)
+		}
+		if count != len(test.expected) {
+			t.Fatalf("Expected count %d for %+v, got %d", len(test.expected), test.condition, count)
+		}
+	}
+
+	// Indexed and scanned operators combine
+	results, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{
+		{Field: "Name", Value: "Ro", Operator: HasPrefix},
+		{Field: "Age", Value: 30, Operator: NotEquals},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if ids(results) != "2" {
+		t.Fatalf("Expected 2, got %s", ids(results))
+	}
+
+	for _, condition := range []Condition{
+		{Field: "Name", Value: 1, Operator: HasPrefix},
+		{Field: "Name", Value: "(", Operator: Regexp},
+		{Field: "Age", Value: 30, Operator: In},
+	} {
+		_, err := store.GetQuery(context.Background(), &Query{Conditions: []Condition{condition}})
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Fatalf("Expected InvalidQueryError for %+v, got %v", condition, err)
+		}
+	}
+}
+
+func TestCompositeIndexIgnoresOtherOperators(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestTask](db, "tasks")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = store.PutBatch(context.Background(), []TestTask{
+		{ID: "a", Status: "open", CreatedAt: base},
+		{ID: "b", Status: "open", CreatedAt: base.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// Only equality and ranges are answered by the composite index, the rest is matched by scanning
+	conditions := []Condition{{Field: "Status", Value: "open"}, {Field: "CreatedAt", Value: base, Operator: NotEquals}}
+	if match, _ := store.matchComposite(conditions); match.count != 1 {
+		t.Fatalf("Expected the composite index to answer 1 condition, got %d", match.count)
+	}
+	results, err := store.GetQuery(context.Background(), &Query{Conditions: conditions})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "b" {
+		t.Fatalf("Expected b, got %+v", results)
+	}
+}
+
+func TestMatchLike(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		value    string
+		pattern  string
+		expected bool
+	}{
+		{"", "", true},
+		{"", "%", true},
+		{"abc", "abc", true},
+		{"abc", "a%", true},
+		{"abc", "%c", true},
+		{"abc", "%b%", true},
+		{"abc", "a_c", true},
+		{"abc", "a_", false},
+		{"abcbd", "a%b_", true},
+		{"ábc", "_bc", true},
+		{"abc", "%d%", false},
+	} {
+		if matchLike(test.value, test.pattern) != test.expected {
+			t.Errorf("Expected matching %q against %q to be %v", test.value, test.pattern, test.expected)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package nnut
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// getOrderedFilterKeys returns the keys of the records matching the filter of a query in the order of its index,
+// past the entry after if not nil, sharing the key prefix and leaving out soft-deleted records when requested,
+// up to maxKeys if >0.
+// The index is walked in order while matching records as they pass when the matches are dense enough to fill
+// the page early, otherwise all matches are collected and sorted.
+func (v *StoreView[T]) getOrderedFilterKeys(query *Query, order resultOrder, after *sortEntry, keyPrefix string, excludeDeleted bool, maxKeys int) []string {
+	index, _ := v.store.resolveIndex(query.Index)
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	keep := func(key string) bool {
+		return strings.HasPrefix(key, keyPrefix) && (!excludeDeleted || v.snapshot.get(tombstoneBucket, []byte(key)) == nil)
+	}
+	if !query.hasFilter() {
+		return v.walkIndexForExpression(index, order.keyDesc, after, nil, keep, maxKeys)
+	}
+
+	if maxKeys > 0 {
+		// Records without a value for the index are missing from it, yet sort first, so they cannot be walked past
+		total := v.countAllKeys()
+		indexSize := v.countKeysFromIndex(index)
+		if indexSize == total {
+			expression := v.store.queryExpression(query)
+			estimate := v.estimateExpressionKeys(expression, total)
+			// The walk passes about maxKeys*indexSize/estimate entries, compared with the estimate of matches to sort
+			if estimate > 0 && maxKeys*indexSize/estimate < estimate {
+				return v.walkIndexForExpression(index, order.keyDesc, after, expression, keep, maxKeys)
+			}
+		}
+	}
+
+	keys := v.getFilterKeys(query, 0)
+	filtered := keys[:0]
+	for _, key := range keys {
+		if keep(key) {
+			filtered = append(filtered, key)
+		}
+	}
+	return v.sortKeysBy(filtered, order, maxKeys, after)
+}
+
+// estimateExpressionKeys estimates the number of records matching an expression from the sizes of its index
+// lookups, counting every record for the parts no index answers
+func (v *StoreView[T]) estimateExpressionKeys(expression Expression, total int) int {
+	switch expression := expression.(type) {
+	case Condition:
+		if v.store.indexedCondition(expression) {
+			return v.countKeysForCondition(expression, 0)
+		}
+	case andExpression:
+		estimate := total
+		for _, child := range expression {
+			estimate = min(estimate, v.estimateExpressionKeys(child, total))
+		}
+		return estimate
+	case orExpression:
+		estimate := 0
+		for _, child := range expression {
+			estimate += v.estimateExpressionKeys(child, total)
+		}
+		return min(estimate, total)
+	}
+	// Negations rarely narrow down the records much
+	return total
+}
+
+// walkIndexForExpression walks an index in order, starting past the entry after if not nil, and returns the keys of
+// the kept records matching the expression, or of all kept records when it is nil, up to maxKeys if >0
+func (v *StoreView[T]) walkIndexForExpression(index string, descending bool, after *sortEntry, expression Expression, keep func(string) bool, maxKeys int) []string {
+	var seek []byte
+	if after != nil {
+		var ok bool
+		if seek, ok = v.store.indexSeek(index, *after); !ok && descending {
+			// Records without a value sort before every entry of the index
+			return nil
+		}
+	}
+	if expression != nil {
+		expression = prepareExpression(expression)
+	}
+	layout := v.store.indexLayout(index)
+	cursor := v.snapshot.cursor([]byte(string(v.store.bucket) + "_index_" + index))
+
+	var keys []string
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for keyBytes, _ := seekPast(cursor, seek, descending); keyBytes != nil && (maxKeys == 0 || len(keys) < maxKeys); keyBytes, _ = stepCursor(cursor, descending) {
+		_, entryKey, ok := layout.split(keyBytes)
+		if !ok || !keep(string(entryKey)) {
+			continue
+		}
+		if expression != nil {
+			data := v.snapshot.get(v.store.bucket, entryKey)
+			if data == nil {
+				continue
+			}
+			var item T
+			decoder.Reset(bytes.NewReader(data))
+			if err := decoder.Decode(&item); err != nil {
+				continue
+			}
+			if !v.store.matchesExpression(item, expression) {
+				continue
+			}
+		}
+		keys = append(keys, string(entryKey))
+	}
+	return keys
+}
+
+// indexSeek returns the index entry of a record positioned at an entry, reporting false when a value is missing,
+// as the record then sorts before every entry of the index
+func (s *Store[T]) indexSeek(index string, entry sortEntry) ([]byte, bool) {
+	for _, value := range entry.values {
+		if value == nil {
+			return nil, false
+		}
+	}
+	var lookup interface{} = entry.values
+	if len(entry.values) == 1 {
+		lookup = entry.values[0]
+	}
+	encoded, ok := s.encodeIndexLookup(index, lookup)
+	if !ok {
+		return nil, false
+	}
+	return []byte(encoded + "\x00" + entry.key), true
+}
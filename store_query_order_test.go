@@ -0,0 +1,193 @@
+package nnut
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestFilteredIndexOrder(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestUser](db, "users", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Ages run against the primary keys, so key order and index order differ
+	var users []TestUser
+	for i := 0; i < 60; i++ {
+		name := "even"
+		if i%2 == 1 {
+			name = "odd"
+		}
+		if i%20 == 0 {
+			name = "rare"
+		}
+		users = append(users, TestUser{UUID: fmt.Sprintf("%02d", i), Name: name, Email: fmt.Sprintf("%02d@example.com", i), Age: (60 - i) / 3})
+	}
+	err = store.PutBatch(context.Background(), users[:30])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), users[30:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Delete(context.Background(), "03")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	expected := func(match func(TestUser) bool, sorting Sorting) []string {
+		var matches []TestUser
+		for _, user := range users {
+			if user.UUID != "03" && match(user) {
+				matches = append(matches, user)
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			if matches[i].Age != matches[j].Age {
+				return (matches[i].Age < matches[j].Age) == (sorting != Descending)
+			}
+			return (matches[i].UUID < matches[j].UUID) == (sorting != Descending)
+		})
+		var keys []string
+		for _, match := range matches {
+			keys = append(keys, match.UUID)
+		}
+		return keys
+	}
+
+	for _, test := range []struct {
+		query *Query
+		match func(TestUser) bool
+	}{
+		// Dense matches are found by walking the index
+		{&Query{Conditions: []Condition{{Field: "Email", Value: "%@example.com", Operator: Like}}}, func(TestUser) bool { return true }},
+		{&Query{Conditions: []Condition{{Field: "Name", Value: "odd"}}}, func(user TestUser) bool { return user.Name == "odd" }},
+		// Sparse matches are collected and sorted
+		{&Query{Conditions: []Condition{{Field: "Name", Value: "rare"}}}, func(user TestUser) bool { return user.Name == "rare" }},
+		{&Query{Where: Or(Condition{Field: "Name", Value: "rare"}, Condition{Field: "UUID", Value: "1", Operator: HasPrefix})}, func(user TestUser) bool {
+			return user.Name == "rare" || user.UUID[0] == '1'
+		}},
+	} {
+		for _, sorting := range []Sorting{Ascending, Descending} {
+			keys := expected(test.match, sorting)
+			for _, page := range []struct{ offset, limit int }{{0, 0}, {0, 4}, {4, 4}, {len(keys) - 2, 5}} {
+				query := *test.query
+				query.Index = "age"
+				query.Sort = sorting
+				query.Offset = page.offset
+				query.Limit = page.limit
+				results, err := store.GetQuery(context.Background(), &query)
+				if err != nil {
+					t.Fatalf("Failed to query: %v", err)
+				}
+				start, end := min(page.offset, len(keys)), len(keys)
+				if page.limit > 0 && page.offset+page.limit < end {
+					end = page.offset + page.limit
+				}
+				want := keys[start:end]
+				if len(results) != len(want) {
+					t.Fatalf("Expected %v for %+v, got %+v", want, query, results)
+				}
+				for position, result := range results {
+					if result.UUID != want[position] {
+						t.Fatalf("Expected %v for %+v, got %+v", want, query, results)
+					}
+				}
+
+				// Iterating yields the same page
+				position := 0
+				store.Iter(context.Background(), &query)(func(user TestUser, err error) bool {
+					if err != nil {
+						t.Fatalf("Failed to iterate: %v", err)
+					}
+					if position >= len(want) || user.UUID != want[position] {
+						t.Fatalf("Expected %v for %+v, got %s at %d", want, query, user.UUID, position)
+					}
+					position++
+					return true
+				})
+				if position != len(want) {
+					t.Fatalf("Expected %d records for %+v, got %d", len(want), query, position)
+				}
+			}
+		}
+	}
+}
+
+func TestFilteredIndexOrderPlan(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	var users []TestUser
+	for i := 0; i < 20; i++ {
+		users = append(users, TestUser{UUID: fmt.Sprintf("%02d", i), Name: fmt.Sprintf("name%d", i%4), Age: i})
+	}
+	err = store.PutBatch(context.Background(), users)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// Estimates follow the index statistics, and count every record where no index answers
+	name := Condition{Field: "Name", Value: "name1"}
+	old := Condition{Field: "Age", Value: 15, Operator: GreaterThanOrEqual}
+	scanned := Condition{Field: "Email", Value: "%", Operator: Like}
+	err = db.view(context.Background(), store.bucket, nil, func(snapshot *Snapshot) error {
+		view := store.In(snapshot)
+		for _, test := range []struct {
+			expression Expression
+			expected   int
+		}{
+			{name, 5},
+			{old, 5},
+			{scanned, 20},
+			{And(name, old), 5},
+			{And(name, scanned), 5},
+			{Or(name, old), 10},
+			{Or(name, scanned), 20},
+			{Not(name), 20},
+		} {
+			if estimate := view.estimateExpressionKeys(test.expression, 20); estimate != test.expected {
+				t.Fatalf("Expected an estimate of %d for %+v, got %d", test.expected, test.expression, estimate)
+			}
+		}
+
+		// Walking the index and sorting the matches agree on the order
+		keep := func(string) bool { return true }
+		walked := view.walkIndexForExpression("age", true, nil, name, keep, 0)
+		sorted := view.sortKeysBy(view.getExpressionKeys(name, 0), store.resultOrder(&Query{Index: "age", Sort: Descending}), 0, nil)
+		if fmt.Sprint(walked) != "[17 13 09 05 01]" || fmt.Sprint(sorted) != fmt.Sprint(walked) {
+			t.Fatalf("Expected [17 13 09 05 01] from both, got %v and %v", walked, sorted)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to view: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package nnut
+
+import (
+	"bytes"
+	"container/heap"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// sortEntry is a record reduced to its key and the values it is ordered by, nil for fields without a value
+type sortEntry struct {
+	key    string
+	values []interface{}
+}
+
+// sortHeap holds the best entries found so far with the worst entry on top
+type sortHeap struct {
+	entries []sortEntry
+	less    func(a, b sortEntry) bool
+}
+
+func (h *sortHeap) Len() int           { return len(h.entries) }
+func (h *sortHeap) Less(i, j int) bool { return h.less(h.entries[j], h.entries[i]) }
+func (h *sortHeap) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *sortHeap) Push(x interface{}) { h.entries = append(h.entries, x.(sortEntry)) }
+func (h *sortHeap) Pop() interface{} {
+	entry := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return entry
+}
+
+// resultOrder describes the order of query results, by the values of fields followed by the key
+type resultOrder struct {
+	fields  []int  // fields compared in order of precedence
+	desc    []bool // fields compared in descending order
+	keyDesc bool   // keys compared in descending order
+}
+
+// resultOrder returns the order of the results of a query, by its sort keys, by its index or by key
+func (s *Store[T]) resultOrder(query *Query) resultOrder {
+	var order resultOrder
+	if len(query.OrderBy) > 0 {
+		for _, sortKey := range query.OrderBy {
+			fieldIndex, _ := s.resolveField(sortKey.Field)
+			order.fields = append(order.fields, fieldIndex)
+			order.desc = append(order.desc, sortKey.Desc)
+		}
+	} else if query.Index != "" {
+		// Index entries hold the value followed by the key, so equal values are walked in key order too
+		index, _ := s.resolveIndex(query.Index)
+		order.fields = s.indexFields[index]
+		order.keyDesc = query.Sort == Descending
+		for range order.fields {
+			order.desc = append(order.desc, order.keyDesc)
+		}
+	}
+	return order
+}
+
+// reversed returns the opposite order
+func (order resultOrder) reversed() resultOrder {
+	reversed := resultOrder{fields: order.fields, desc: make([]bool, len(order.desc)), keyDesc: !order.keyDesc}
+	for position, desc := range order.desc {
+		reversed.desc[position] = !desc
+	}
+	return reversed
+}
+
+// compare compares two entries in the order, returning a negative number when a comes first
+func (order resultOrder) compare(a, b sortEntry) int {
+	for position, desc := range order.desc {
+		comparison := compareSortValues(a.values[position], b.values[position])
+		if desc {
+			comparison = -comparison
+		}
+		if comparison != 0 {
+			return comparison
+		}
+	}
+	comparison := strings.Compare(a.key, b.key)
+	if order.keyDesc {
+		comparison = -comparison
+	}
+	return comparison
+}
+
+// sortKeysBy orders keys by the values of their records, returning the first maxKeys if >0 that come after the
+// entry after if not nil. Small pages are selected through a heap, so only maxKeys records are held while the rest
+// pass by.
+func (v *StoreView[T]) sortKeysBy(keys []string, order resultOrder, maxKeys int, after *sortEntry) []string {
+	less := func(a, b sortEntry) bool {
+		return order.compare(a, b) < 0
+	}
+
+	useHeap := maxKeys > 0 && maxKeys < len(keys)
+	best := &sortHeap{less: less}
+	var entries []sortEntry
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for _, key := range keys {
+		data := v.snapshot.get(v.store.bucket, []byte(key))
+		if data == nil {
+			continue
+		}
+		var item T
+		decoder.Reset(bytes.NewReader(data))
+		if err := decoder.Decode(&item); err != nil {
+			continue
+		}
+		entry := sortEntry{key: key, values: v.store.sortValues(reflect.ValueOf(item), order.fields)}
+		if after != nil && !less(*after, entry) {
+			continue
+		}
+		if !useHeap {
+			entries = append(entries, entry)
+		} else if best.Len() < maxKeys {
+			heap.Push(best, entry)
+		} else if less(entry, best.entries[0]) {
+			best.entries[0] = entry
+			heap.Fix(best, 0)
+		}
+	}
+	if useHeap {
+		entries = best.entries
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
+	sorted := make([]string, len(entries))
+	for position, entry := range entries {
+		sorted[position] = entry.key
+	}
+	return sorted
+}
+
+// sortValues returns the values of the fields of a record, collated where their index asks for it
+func (s *Store[T]) sortValues(item reflect.Value, fieldIndexes []int) []interface{} {
+	values := make([]interface{}, len(fieldIndexes))
+	for position, fieldIndex := range fieldIndexes {
+		fieldValue, ok := s.fieldValue(item, fieldIndex)
+		if !ok {
+			continue
+		}
+		values[position] = fieldValue.Interface()
+		if collation, ok := s.collations[fieldIndex]; ok {
+			values[position] = collation.applyValue(values[position])
+		}
+	}
+	return values
+}
+
+// compareSortValues compares two sort values, with values missing from a record sorting first
+func compareSortValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return compare(a, b)
+}
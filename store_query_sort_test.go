@@ -0,0 +1,166 @@
+package nnut
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type TestEmployee struct {
+	ID        string `nnut:"key"`
+	LastName  string
+	Team      string `nnut:"index:team"`
+	Level     uint8
+	Salary    float64
+	HiredAt   time.Time
+	Languages []string
+}
+
+func TestOrderBy(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestEmployee](db, "employees")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastNames := []string{"Smith", "Jones", "Brown", "Smith", "Adams"}
+	var employees []TestEmployee
+	for i := 0; i < 40; i++ {
+		employees = append(employees, TestEmployee{
+			ID:       fmt.Sprintf("%02d", i),
+			LastName: lastNames[i%len(lastNames)],
+			Team:     []string{"core", "web"}[i%2],
+			Level:    uint8(i % 3),
+			Salary:   float64(i%7) * 1000.5,
+			HiredAt:  base.AddDate(0, 0, i%4),
+		})
+	}
+	err = store.PutBatch(context.Background(), employees[:20])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), employees[20:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	expected := func(match func(TestEmployee) bool, less func(a, b TestEmployee) int) []string {
+		var matches []TestEmployee
+		for _, employee := range employees {
+			if match(employee) {
+				matches = append(matches, employee)
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			if comparison := less(matches[i], matches[j]); comparison != 0 {
+				return comparison < 0
+			}
+			return matches[i].ID < matches[j].ID
+		})
+		var keys []string
+		for _, match := range matches {
+			keys = append(keys, match.ID)
+		}
+		return keys
+	}
+	all := func(TestEmployee) bool { return true }
+
+	for _, test := range []struct {
+		conditions []Condition
+		orderBy    []SortKey
+		match      func(TestEmployee) bool
+		compare    func(a, b TestEmployee) int
+	}{
+		{nil, []SortKey{{Field: "LastName"}, {Field: "HiredAt", Desc: true}}, all, func(a, b TestEmployee) int {
+			if comparison := strings.Compare(a.LastName, b.LastName); comparison != 0 {
+				return comparison
+			}
+			return -a.HiredAt.Compare(b.HiredAt)
+		}},
+		{nil, []SortKey{{Field: "Salary", Desc: true}, {Field: "Level"}}, all, func(a, b TestEmployee) int {
+			if a.Salary != b.Salary {
+				return compareOrdered(b.Salary, a.Salary)
+			}
+			return compareOrdered(int64(a.Level), int64(b.Level))
+		}},
+		{[]Condition{{Field: "Team", Value: "web"}}, []SortKey{{Field: "Level", Desc: true}}, func(employee TestEmployee) bool { return employee.Team == "web" }, func(a, b TestEmployee) int {
+			return compareOrdered(int64(b.Level), int64(a.Level))
+		}},
+	} {
+		keys := expected(test.match, test.compare)
+		for _, page := range []struct{ offset, limit int }{{0, 0}, {0, 3}, {5, 5}, {len(keys) - 2, 10}} {
+			query := &Query{Conditions: test.conditions, OrderBy: test.orderBy, Offset: page.offset, Limit: page.limit}
+			results, err := store.GetQuery(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			end := len(keys)
+			if page.limit > 0 && page.offset+page.limit < end {
+				end = page.offset + page.limit
+			}
+			want := keys[page.offset:end]
+			var got []string
+			for _, result := range results {
+				got = append(got, result.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("Expected %v for %+v, got %v", want, query, got)
+			}
+
+			var iterated []string
+			store.Iter(context.Background(), query)(func(employee TestEmployee, err error) bool {
+				if err != nil {
+					t.Fatalf("Failed to iterate: %v", err)
+				}
+				iterated = append(iterated, employee.ID)
+				return true
+			})
+			if fmt.Sprint(iterated) != fmt.Sprint(want) {
+				t.Fatalf("Expected %v when iterating %+v, got %v", want, query, iterated)
+			}
+		}
+	}
+
+	// Deleting the first records in order removes exactly those
+	deleted, err := store.DeleteQuery(context.Background(), &Query{OrderBy: []SortKey{{Field: "Salary", Desc: true}}, Limit: 3})
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if deleted != 3 {
+		t.Fatalf("Expected 3 deleted records, got %d", deleted)
+	}
+	results, err := store.GetQuery(context.Background(), &Query{OrderBy: []SortKey{{Field: "Salary", Desc: true}}, Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "27" {
+		t.Fatalf("Expected 27, got %+v", results)
+	}
+
+	for _, query := range []*Query{
+		{OrderBy: []SortKey{{Field: "Missing"}}},
+		{OrderBy: []SortKey{{Field: "Languages"}}},
+		{OrderBy: []SortKey{{Field: "Team"}}, Index: "team"},
+	} {
+		_, err := store.GetQuery(context.Background(), query)
+		if _, ok := err.(InvalidQueryError); !ok {
+			t.Fatalf("Expected InvalidQueryError for %+v, got %v", query, err)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func TestQuery(t *testing.T) {
@@ -933,6 +935,101 @@ func TestDeleteQuery(t *testing.T) {
 	}
 }
 
+func TestDeleteQueryBuffered(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUsers := []TestUser{
+		{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30},
+		{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25},
+	}
+	err = store.PutBatch(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+
+	// Buffered update and a buffered new record match the query
+	err = store.Put(context.Background(), TestUser{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 40})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	err = store.Put(context.Background(), TestUser{UUID: "3", Name: "Charlie", Email: "charlie@example.com", Age: 50})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	deletedCount, err := store.DeleteQuery(context.Background(), &Query{
+		Conditions: []Condition{
+			{Field: "Age", Value: 35, Operator: GreaterThan},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete query: %v", err)
+	}
+	if deletedCount != 2 {
+		t.Fatalf("Expected 2 deletions, got %d", deletedCount)
+	}
+
+	// Deletions are visible before and after a flush
+	count, err := store.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected count 1, got %d", count)
+	}
+	db.Flush()
+	_, err = store.Get(context.Background(), "2")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError after flush, got %v", err)
+	}
+
+	// Deletions are written to the WAL and survive a restart
+	err = store.Put(context.Background(), TestUser{UUID: "4", Name: "Dave", Email: "dave@example.com", Age: 60})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	_, err = store.DeleteQuery(context.Background(), &Query{
+		Conditions: []Condition{
+			{Field: "Name", Value: "Dave"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete query: %v", err)
+	}
+	db.Close()
+
+	db, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err = NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store after reopen: %v", err)
+	}
+	count, err = store.CountQuery(context.Background(), &Query{Index: "email"})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 indexed record after reopen, got %d", count)
+	}
+}
+
 func TestDeleteQueryLimitOffset(t *testing.T) {
 	t.Parallel()
 	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
@@ -1229,3 +1326,170 @@ func FuzzQueryConditions(f *testing.F) {
 		_, _ = store.DeleteQuery(context.Background(), &Query{Conditions: []Condition{condition}, Limit: 1}) // Test DeleteQuery with limit to avoid deleting everything
 	})
 }
+
+type TestContact struct {
+	UUID  string `nnut:"key"`
+	Email string `nnut:"index:Email"`
+}
+
+func TestQueryUnflushed(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestContact](db, "contacts")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	err = store.Put(context.Background(), TestContact{UUID: "1", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	db.Flush()
+
+	// A buffered record shows up in an indexed query
+	err = store.Put(context.Background(), TestContact{UUID: "2", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	results, err := store.GetQuery(context.Background(), &Query{
+		Conditions: []Condition{{Field: "Email", Value: "bob@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 || results[0].UUID != "2" {
+		t.Fatalf("Expected buffered record, got %v", results)
+	}
+
+	// A buffered update moves a flushed record to its new index value
+	err = store.Put(context.Background(), TestContact{UUID: "1", Email: "carol@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	count, err := store.CountQuery(context.Background(), &Query{
+		Conditions: []Condition{{Field: "Email", Value: "alice@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Expected count 0 for old value, got %d", count)
+	}
+	count, err = store.CountQuery(context.Background(), &Query{
+		Conditions: []Condition{{Field: "Email", Value: "carol@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected count 1 for new value, got %d", count)
+	}
+
+	// Sorting by the index includes buffered entries
+	results, err = store.GetQuery(context.Background(), &Query{Index: "Email", Sort: Descending})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 2 || results[0].Email != "carol@example.com" || results[1].Email != "bob@example.com" {
+		t.Fatalf("Expected carol then bob, got %v", results)
+	}
+
+	// A buffered delete removes the record from the index
+	err = store.Delete(context.Background(), "2")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	count, err = store.CountQuery(context.Background(), &Query{Index: "Email"})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected count 1 after delete, got %d", count)
+	}
+
+	// Flushing does not change the results
+	db.Flush()
+	count, err = store.CountQuery(context.Background(), &Query{Index: "Email"})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected count 1 after flush, got %d", count)
+	}
+}
+
+// TestRacingUser runs racingUserDecoded the first time a record is decoded after it is set, to write while
+// the record is being deleted
+type TestRacingUser struct {
+	UUID string `nnut:"key"`
+	Age  int    `nnut:"index:age"`
+}
+
+var racingUserDecoded func()
+
+func (user *TestRacingUser) DecodeMsgpack(decoder *msgpack.Decoder) error {
+	type plain TestRacingUser
+	if err := decoder.Decode((*plain)(user)); err != nil {
+		return err
+	}
+	if decoded := racingUserDecoded; decoded != nil {
+		racingUserDecoded = nil
+		decoded()
+	}
+	return nil
+}
+
+func TestDeleteQueryConcurrentWrite(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestRacingUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.Put(context.Background(), TestRacingUser{UUID: "1", Age: 30})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	// A write to a matching record while the deletion is prepared makes it start over from the record
+	var putErr error
+	racingUserDecoded = func() {
+		putErr = store.Put(context.Background(), TestRacingUser{UUID: "1", Age: 31})
+	}
+	deletedCount, err := store.DeleteQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to delete query: %v", err)
+	}
+	if putErr != nil {
+		t.Fatalf("Failed to put: %v", putErr)
+	}
+	if deletedCount != 1 {
+		t.Fatalf("Expected 1 deletion, got %d", deletedCount)
+	}
+
+	// No index entry is left behind for the deleted record
+	db.Flush()
+	count, err := store.CountQuery(context.Background(), &Query{Index: "age"})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Expected no index entries, got %d", count)
+	}
+}
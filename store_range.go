@@ -0,0 +1,211 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// RangeOptions controls the order and size of a key range or prefix scan
+type RangeOptions struct {
+	Sort   Sorting // Ascending (default) or Descending key order
+	Limit  int     // Maximum number of results to return (0 = no limit)
+	Cursor string  // Continue after the last result of a previous scan with the same bounds
+
+	IncludeDeleted bool // Include soft-deleted records in the results
+}
+
+// Range returns the records with keys from from up to but not including to, in key order.
+// A nil bound leaves that side of the range open. Composite keys may be bounded by their leading parts.
+// When the limit cuts the scan short a cursor is returned to continue from, otherwise the cursor is empty.
+func (s *Store[T]) Range(ctx context.Context, from, to interface{}, opts *RangeOptions) ([]T, string, error) {
+	var results []T
+	var cursor string
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		results, cursor, err = s.In(snapshot).Range(ctx, from, to, opts)
+		return err
+	})
+	return results, cursor, err
+}
+
+// Range returns the records with keys from from up to but not including to as of the snapshot
+func (v *StoreView[T]) Range(ctx context.Context, from, to interface{}, opts *RangeOptions) ([]T, string, error) {
+	lower, err := v.store.encodeRangeBound(from)
+	if err != nil {
+		return nil, "", err
+	}
+	upper, err := v.store.encodeRangeBound(to)
+	if err != nil {
+		return nil, "", err
+	}
+	return v.scan(ctx, lower, upper, opts)
+}
+
+// Prefix returns the records with keys starting with the prefix, in key order.
+// String keys take a string prefix, composite keys take their leading parts.
+func (s *Store[T]) Prefix(ctx context.Context, prefix interface{}, opts *RangeOptions) ([]T, string, error) {
+	var results []T
+	var cursor string
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		results, cursor, err = s.In(snapshot).Prefix(ctx, prefix, opts)
+		return err
+	})
+	return results, cursor, err
+}
+
+// Prefix returns the records with keys starting with the prefix as of the snapshot
+func (v *StoreView[T]) Prefix(ctx context.Context, prefix interface{}, opts *RangeOptions) ([]T, string, error) {
+	lower, err := v.store.encodePrefix(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	return v.scan(ctx, lower, prefixEnd(lower), opts)
+}
+
+// encodeRangeBound converts a range bound into its stored form, returning nil for an open bound
+func (s *Store[T]) encodeRangeBound(bound interface{}) ([]byte, error) {
+	if bound == nil {
+		return nil, nil
+	}
+	if len(s.keyParts) > 1 {
+		if parts, ok := bound.([]interface{}); ok && len(parts) < len(s.keyParts) {
+			encoded, err := s.encodeKeyPrefix(parts)
+			return []byte(encoded), err
+		}
+	}
+	encoded, err := s.encodeKey(bound)
+	return []byte(encoded), err
+}
+
+// encodePrefix converts a key prefix into the stored prefix shared by all matching keys
+func (s *Store[T]) encodePrefix(prefix interface{}) ([]byte, error) {
+	if prefix == nil {
+		return nil, s.keyTypeError(0, "nil")
+	}
+	if len(s.keyParts) > 1 {
+		parts, ok := prefix.([]interface{})
+		if !ok {
+			return nil, s.keyTypeError(0, reflect.TypeOf(prefix).String())
+		}
+		encoded, err := s.encodeKeyPrefix(parts)
+		return []byte(encoded), err
+	}
+	// Only string keys share readable prefixes, the other key types are encoded as fixed-width numbers
+	text, ok := prefix.(string)
+	if !ok || s.keyParts[0].kind != stringKey {
+		return nil, s.keyTypeError(0, reflect.TypeOf(prefix).String())
+	}
+	return []byte(text), nil
+}
+
+// prefixEnd returns the smallest key greater than every key starting with the prefix, or nil when there is none
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for index := len(end) - 1; index >= 0; index-- {
+		end[index]++
+		if end[index] != 0 {
+			return end[:index+1]
+		}
+	}
+	return nil
+}
+
+// scan walks the stored keys from lower up to but not including upper, where nil leaves a side open
+func (v *StoreView[T]) scan(ctx context.Context, lower, upper []byte, opts *RangeOptions) ([]T, string, error) {
+	if opts == nil {
+		opts = &RangeOptions{}
+	}
+	if opts.Limit < 0 {
+		return nil, "", InvalidQueryError{Field: "Limit", Value: opts.Limit, Reason: "cannot be negative"}
+	}
+	descending := opts.Sort == Descending
+
+	// Continue strictly after the key the cursor points at
+	var after []byte
+	if opts.Cursor != "" {
+		var err error
+		after, err = base64.RawURLEncoding.DecodeString(opts.Cursor)
+		if err != nil || len(after) == 0 {
+			return nil, "", InvalidQueryError{Field: "Cursor", Value: opts.Cursor, Reason: "is not a cursor returned by a scan"}
+		}
+	}
+
+	cursor := v.snapshot.cursor(v.store.bucket)
+	var keyBytes, data []byte
+	if !descending {
+		start := lower
+		if after != nil && bytes.Compare(after, start) >= 0 {
+			start = after
+		}
+		if start == nil {
+			keyBytes, data = cursor.First()
+		} else {
+			keyBytes, data = cursor.Seek(start)
+		}
+		if keyBytes != nil && after != nil && bytes.Equal(keyBytes, after) {
+			keyBytes, data = cursor.Next()
+		}
+	} else {
+		end := upper
+		if after != nil && (end == nil || bytes.Compare(after, end) < 0) {
+			end = after
+		}
+		if end == nil {
+			keyBytes, data = cursor.Last()
+		} else if keyBytes, data = cursor.Seek(end); keyBytes == nil {
+			keyBytes, data = cursor.Last()
+		} else {
+			keyBytes, data = cursor.Prev()
+		}
+	}
+
+	var results []T
+	var lastKey []byte
+	tombstoneBucket := tombstoneBucketName(v.store.bucket)
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for ; keyBytes != nil; keyBytes, data = stepCursor(cursor, descending) {
+		if !descending && upper != nil && bytes.Compare(keyBytes, upper) >= 0 {
+			break
+		}
+		if descending && lower != nil && bytes.Compare(keyBytes, lower) < 0 {
+			break
+		}
+		if !opts.IncludeDeleted && v.snapshot.get(tombstoneBucket, keyBytes) != nil {
+			continue
+		}
+		if opts.Limit > 0 && len(results) == opts.Limit {
+			// Another record follows, so hand out a cursor to continue from
+			return results, base64.RawURLEncoding.EncodeToString(lastKey), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		default:
+		}
+
+		var item T
+		decoder.Reset(bytes.NewReader(data))
+		err := decoder.Decode(&item)
+		if err != nil {
+			return nil, "", WrappedError{Operation: "decode", Bucket: string(v.store.bucket), Key: v.store.formatKey(string(keyBytes)), Err: err}
+		}
+		results = append(results, item)
+		lastKey = append(lastKey[:0], keyBytes...)
+	}
+	return results, "", nil
+}
+
+// stepCursor moves the cursor one key in the scan direction
+func stepCursor(cursor *overlayCursor, descending bool) ([]byte, []byte) {
+	if descending {
+		return cursor.Prev()
+	}
+	return cursor.Next()
+}
@@ -0,0 +1,189 @@
+package nnut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestEvent](db, "events")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Mix flushed and buffered records
+	var events []TestEvent
+	for id := -3; id <= 3; id++ {
+		events = append(events, TestEvent{ID: id})
+	}
+	err = store.PutBatch(context.Background(), events[:4])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.PutBatch(context.Background(), events[4:])
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	err = store.Delete(context.Background(), -2)
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	ids := func(results []TestEvent) []int {
+		var ids []int
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
+	expect := func(results []TestEvent, expected ...int) {
+		t.Helper()
+		actual := ids(results)
+		if len(actual) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, actual)
+		}
+		for index := range expected {
+			if actual[index] != expected[index] {
+				t.Fatalf("Expected %v, got %v", expected, actual)
+			}
+		}
+	}
+
+	results, cursor, err := store.Range(context.Background(), -3, 2, nil)
+	if err != nil {
+		t.Fatalf("Failed to range: %v", err)
+	}
+	expect(results, -3, -1, 0, 1)
+	if cursor != "" {
+		t.Fatalf("Expected no cursor, got %q", cursor)
+	}
+
+	results, _, err = store.Range(context.Background(), nil, nil, &RangeOptions{Sort: Descending})
+	if err != nil {
+		t.Fatalf("Failed to range: %v", err)
+	}
+	expect(results, 3, 2, 1, 0, -1, -3)
+
+	results, _, err = store.Range(context.Background(), 0, nil, &RangeOptions{Sort: Descending})
+	if err != nil {
+		t.Fatalf("Failed to range: %v", err)
+	}
+	expect(results, 3, 2, 1, 0)
+
+	// Follow the cursor through the range in both directions
+	for _, sorting := range []Sorting{Ascending, Descending} {
+		var all []TestEvent
+		cursor = ""
+		for pages := 0; pages < 10; pages++ {
+			results, cursor, err = store.Range(context.Background(), -1, 3, &RangeOptions{Sort: sorting, Limit: 2, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("Failed to range: %v", err)
+			}
+			all = append(all, results...)
+			if cursor == "" {
+				break
+			}
+		}
+		if sorting == Ascending {
+			expect(all, -1, 0, 1, 2)
+		} else {
+			expect(all, 2, 1, 0, -1)
+		}
+	}
+
+	_, _, err = store.Range(context.Background(), nil, nil, &RangeOptions{Cursor: "%"})
+	if _, ok := err.(InvalidQueryError); !ok {
+		t.Fatalf("Expected InvalidQueryError, got %v", err)
+	}
+	_, _, err = store.Range(context.Background(), "a", nil, nil)
+	if _, ok := err.(InvalidFieldTypeError); !ok {
+		t.Fatalf("Expected InvalidFieldTypeError, got %v", err)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = store.PutBatch(context.Background(), []TestUser{
+		{UUID: "user:1", Name: "Alice"},
+		{UUID: "user:2", Name: "Bob"},
+		{UUID: "group:1", Name: "Admins"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+	err = store.Put(context.Background(), TestUser{UUID: "user:3", Name: "Charlie"})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	results, _, err := store.Prefix(context.Background(), "user:", &RangeOptions{Sort: Descending})
+	if err != nil {
+		t.Fatalf("Failed to scan prefix: %v", err)
+	}
+	if len(results) != 3 || results[0].Name != "Charlie" || results[2].Name != "Alice" {
+		t.Fatalf("Expected Charlie, Bob, Alice, got %v", results)
+	}
+
+	results, cursor, err := store.Prefix(context.Background(), "user:", &RangeOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to scan prefix: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "Alice" || cursor == "" {
+		t.Fatalf("Expected Alice and a cursor, got %v and %q", results, cursor)
+	}
+	results, _, err = store.Prefix(context.Background(), "user:", &RangeOptions{Limit: 5, Cursor: cursor})
+	if err != nil {
+		t.Fatalf("Failed to scan prefix: %v", err)
+	}
+	if len(results) != 2 || results[0].Name != "Bob" {
+		t.Fatalf("Expected Bob and Charlie, got %v", results)
+	}
+
+	// Composite keys take their leading parts
+	memberships, err := NewStore[TestMembership](db, "memberships")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	err = memberships.PutBatch(context.Background(), []TestMembership{
+		{Tenant: "acme", ID: 2},
+		{Tenant: "acme", ID: 1},
+		{Tenant: "acme2", ID: 1},
+	})
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	members, _, err := memberships.Prefix(context.Background(), []interface{}{"acme"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to scan prefix: %v", err)
+	}
+	if len(members) != 2 || members[0].ID != 1 || members[1].ID != 2 {
+		t.Fatalf("Expected acme members 1 and 2, got %v", members)
+	}
+}
@@ -334,3 +334,45 @@ func TestWALReplayWithBuffer(t *testing.T) {
 		t.Fatalf("WAL replay failed: got %s, want %s", retrieved.Name, testUser.Name)
 	}
 }
+
+func TestBufferDeduplicationIndexes(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	err = store.Put(context.Background(), TestUser{UUID: "key1", Name: "First", Email: "first@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to put first: %v", err)
+	}
+	db.Flush()
+
+	// Replace the record twice before flushing so the buffer merges both changes
+	err = store.Put(context.Background(), TestUser{UUID: "key1", Name: "Second", Email: "second@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to put second: %v", err)
+	}
+	err = store.Put(context.Background(), TestUser{UUID: "key1", Name: "Third", Email: "third@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to put third: %v", err)
+	}
+	db.Flush()
+
+	count, err := store.CountQuery(context.Background(), &Query{Index: "email"})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected a single index entry, got %d", count)
+	}
+}
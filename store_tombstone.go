@@ -0,0 +1,115 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// softDeleteBatch marks records as deleted while keeping their values and index entries.
+// The records are only marked when none of them changed since they were read, otherwise they are read again.
+func (s *Store[T]) softDeleteBatch(ctx context.Context, keys []string) error {
+	return retryConflicts(func() error {
+		reads, err := s.readRecords(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		deletedAt := time.Now().UnixNano()
+		var operations []operation
+		for _, read := range reads {
+			// Keep the original deletion time of records that are already deleted
+			if read.deletedAt != 0 {
+				continue
+			}
+			operations = append(operations, operation{
+				Bucket:    s.bucket,
+				Key:       read.key,
+				Value:     read.data,
+				IsPut:     true,
+				DeletedAt: deletedAt,
+			})
+		}
+		return s.database.writeCheckedOperations(ctx, operations, s.checkUnchanged(reads))
+	})
+}
+
+// Restore brings back a soft-deleted record
+func (s *Store[T]) Restore(ctx context.Context, key interface{}) error {
+	encodedKey, err := s.encodeKey(key)
+	if err != nil {
+		return err
+	}
+	return retryConflicts(func() error {
+		reads, err := s.readRecords(ctx, []string{encodedKey})
+		if err != nil {
+			return err
+		}
+		if len(reads) == 0 {
+			return KeyNotFoundError{Bucket: string(s.bucket), Key: s.formatKey(encodedKey)}
+		}
+		if reads[0].deletedAt == 0 {
+			// Nothing to restore
+			return nil
+		}
+
+		// Index entries are kept while deleted, so only the tombstone needs clearing
+		operations := []operation{{
+			Bucket: s.bucket,
+			Key:    encodedKey,
+			Value:  reads[0].data,
+			IsPut:  true,
+		}}
+		return s.database.writeCheckedOperations(ctx, operations, s.checkUnchanged(reads))
+	})
+}
+
+// Purge permanently removes records that were soft-deleted longer ago than olderThan and returns the count of purged records
+func (s *Store[T]) Purge(ctx context.Context, olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan).UnixNano()
+
+	// Remove expired records together with their index entries
+	var operations []operation
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		decoder := msgpack.GetDecoder()
+		defer msgpack.PutDecoder(decoder)
+		cursor := snapshot.cursor(tombstoneBucketName(s.bucket))
+		for keyBytes, valueBytes := cursor.First(); keyBytes != nil; keyBytes, valueBytes = cursor.Next() {
+			if decodeTombstone(valueBytes) > cutoff {
+				continue
+			}
+			key := string(keyBytes)
+			data := snapshot.get(s.bucket, keyBytes)
+			if data == nil {
+				continue
+			}
+			var item T
+			decoder.Reset(bytes.NewReader(data))
+			if err := decoder.Decode(&item); err != nil {
+				return WrappedError{Operation: "decode", Bucket: string(s.bucket), Key: s.formatKey(key), Err: err}
+			}
+
+			indexOperations := s.indexOperations(s.extractIndexValues(item), nil)
+
+			operations = append(operations, operation{
+				Bucket:          s.bucket,
+				Key:             key,
+				Value:           nil,
+				IsPut:           false,
+				IndexOperations: indexOperations,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.database.writeOperations(ctx, operations)
+	if err != nil {
+		return 0, err
+	}
+	return len(operations), nil
+}
@@ -0,0 +1,299 @@
+package nnut
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSoftDelete(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestUser](db, "users", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUsers := []TestUser{
+		{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30},
+		{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25},
+		{UUID: "3", Name: "Alice", Email: "alice2@example.com", Age: 35},
+	}
+	err = store.PutBatch(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+
+	err = store.Delete(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	// Deleted record is hidden while buffered
+	_, err = store.Get(context.Background(), "1")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError, got %v", err)
+	}
+	db.Flush()
+
+	// Deleted record is hidden once flushed
+	_, err = store.Get(context.Background(), "1")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError after flush, got %v", err)
+	}
+	results, err := store.GetBatch(context.Background(), []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("Failed to get batch: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	count, err := store.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected count 2, got %d", count)
+	}
+
+	// Queries exclude deleted records unless asked for them
+	query := &Query{
+		Conditions: []Condition{
+			{Field: "Name", Value: "Alice"},
+		},
+	}
+	users, err := store.GetQuery(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(users) != 1 || users[0].UUID != "3" {
+		t.Fatalf("Expected only user 3, got %+v", users)
+	}
+	count, err = store.CountQuery(context.Background(), &Query{Index: "email"})
+	if err != nil {
+		t.Fatalf("Failed to count query: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected count 2, got %d", count)
+	}
+
+	query.IncludeDeleted = true
+	users, err = store.GetQuery(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("Expected 2 results including deleted, got %d", len(users))
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestUser](db, "users", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUser := TestUser{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30}
+	err = store.Put(context.Background(), testUser)
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	err = store.Delete(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	db.Flush()
+
+	err = store.Restore(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	db.Flush()
+
+	retrieved, err := store.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to get after restore: %v", err)
+	}
+	if retrieved.Name != testUser.Name {
+		t.Fatalf("Retrieved data mismatch: got %+v, want %+v", retrieved, testUser)
+	}
+
+	// Index entries survive the soft delete
+	users, err := store.GetQuery(context.Background(), &Query{Index: "email"})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("Expected 1 result after restore, got %d", len(users))
+	}
+
+	err = store.Restore(context.Background(), "missing")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError, got %v", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestUser](db, "users", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUsers := []TestUser{
+		{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30},
+		{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25},
+	}
+	err = store.PutBatch(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+
+	deletedCount, err := store.DeleteQuery(context.Background(), &Query{})
+	if err != nil {
+		t.Fatalf("Failed to delete query: %v", err)
+	}
+	if deletedCount != 2 {
+		t.Fatalf("Expected 2 deletions, got %d", deletedCount)
+	}
+
+	// Recently deleted records are kept
+	purgedCount, err := store.Purge(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purgedCount != 0 {
+		t.Fatalf("Expected 0 purged, got %d", purgedCount)
+	}
+
+	purgedCount, err = store.Purge(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purgedCount != 2 {
+		t.Fatalf("Expected 2 purged, got %d", purgedCount)
+	}
+	db.Flush()
+
+	err = store.Restore(context.Background(), "1")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError after purge, got %v", err)
+	}
+	count, err := store.CountQuery(context.Background(), &Query{Index: "email", IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("Failed to count query: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Expected purged index entries to be removed, got %d", count)
+	}
+}
+
+func TestSoftDeleteConcurrentWrite(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStoreWithConfig[TestUser](db, "users", &StoreConfig{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	err = store.Put(context.Background(), TestUser{UUID: "1", Name: "Alice", Age: 30})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	// A record written after it was read refuses the write made from the stale copy
+	key, _ := store.encodeKey("1")
+	reads, err := store.readRecords(context.Background(), []string{key})
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	err = store.Put(context.Background(), TestUser{UUID: "1", Name: "Alicia", Age: 31})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	stale := []operation{{Bucket: store.bucket, Key: key, Value: reads[0].data, IsPut: true, DeletedAt: 1}}
+	err = db.writeCheckedOperations(context.Background(), stale, store.checkUnchanged(reads))
+	if _, ok := err.(ConflictError); !ok {
+		t.Fatalf("Expected ConflictError, got %v", err)
+	}
+
+	// Deletes and restores racing with puts keep the record in line with its index entries
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 200; i++ {
+			err := store.Put(context.Background(), TestUser{UUID: "1", Name: fmt.Sprintf("Alice%d", i), Age: i})
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for i := 0; i < 200; i++ {
+		if err := store.Delete(context.Background(), "1"); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+		if err := store.Restore(context.Background(), "1"); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	err = store.Restore(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	user, err := store.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	count, err := store.CountQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Name", Value: user.Name}, {Field: "Age", Value: user.Age}}})
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected the index entries of %+v, got %d matches", user, count)
+	}
+}
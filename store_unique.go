@@ -0,0 +1,104 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// GetByUnique retrieves the record holding a value of a unique index.
+// Composite indexes take a slice with a value for each field, in index order.
+func (s *Store[T]) GetByUnique(ctx context.Context, index string, value interface{}) (T, error) {
+	var result T
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		var err error
+		result, err = s.In(snapshot).GetByUnique(ctx, index, value)
+		return err
+	})
+	return result, err
+}
+
+// GetByUnique retrieves the record holding a value of a unique index as of the snapshot
+func (v *StoreView[T]) GetByUnique(ctx context.Context, index string, value interface{}) (T, error) {
+	var zero T
+	if resolved, ok := v.store.resolveIndex(index); ok {
+		index = resolved
+	}
+	if !v.store.unique[index] {
+		return zero, InvalidQueryError{Field: "Index", Value: index, Reason: "not a unique index"}
+	}
+	encoded, ok := v.store.encodeIndexLookup(index, value)
+	if !ok {
+		return zero, InvalidQueryError{Field: "Value", Value: value, Reason: "does not match the type of the index fields"}
+	}
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	default:
+	}
+
+	keys := v.indexKeys(index, encoded)
+	if len(keys) == 0 {
+		return zero, KeyNotFoundError{Bucket: string(v.store.bucket), Key: fmt.Sprint(value)}
+	}
+	// Soft-deleted records keep their value, but are hidden like they are from Get
+	return v.get(keys[0])
+}
+
+// indexKeys returns the keys of the records holding an encoded value of an index
+func (v *StoreView[T]) indexKeys(index string, value string) []string {
+	var keys []string
+	prefix := []byte(value + "\x00")
+	layout := v.store.indexLayout(index)
+	cursor := v.snapshot.cursor([]byte(string(v.store.bucket) + "_index_" + index))
+	for keyBytes, _ := cursor.Seek(prefix); keyBytes != nil && bytes.HasPrefix(keyBytes, prefix); keyBytes, _ = cursor.Next() {
+		if _, key, ok := layout.split(keyBytes); ok {
+			keys = append(keys, string(key))
+		}
+	}
+	return keys
+}
+
+// checkUnique returns a check for writeCheckedOperations that rejects operations giving a unique index a value
+// held by another record, or nil when the store has no unique indexes.
+// Soft-deleted records keep holding their values, so they can always be restored.
+func (s *Store[T]) checkUnique(operations []operation) func(*Snapshot) error {
+	if len(s.unique) == 0 {
+		return nil
+	}
+	return func(snapshot *Snapshot) error {
+		view := s.In(snapshot)
+
+		// Values given up by records written in the same batch are free to take
+		released := make(map[string]bool)
+		for _, operation := range operations {
+			for _, indexOperation := range operation.IndexOperations {
+				if s.unique[indexOperation.IndexName] && indexOperation.OldValue != "" {
+					released[indexOperation.IndexName+"\x00"+indexOperation.OldValue+"\x00"+operation.Key] = true
+				}
+			}
+		}
+
+		claimed := make(map[string]string)
+		for _, operation := range operations {
+			for _, indexOperation := range operation.IndexOperations {
+				name := indexOperation.IndexName
+				if !s.unique[name] || indexOperation.NewValue == "" {
+					continue
+				}
+				claim := name + "\x00" + indexOperation.NewValue
+				if holder, exists := claimed[claim]; exists && holder != operation.Key {
+					return UniqueConstraintError{Bucket: string(s.bucket), Index: name, Key: s.formatKey(holder)}
+				}
+				claimed[claim] = operation.Key
+
+				for _, holder := range view.indexKeys(name, indexOperation.NewValue) {
+					if holder != operation.Key && !released[claim+"\x00"+holder] {
+						return UniqueConstraintError{Bucket: string(s.bucket), Index: name, Key: s.formatKey(holder)}
+					}
+				}
+			}
+		}
+		return nil
+	}
+}
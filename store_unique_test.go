@@ -0,0 +1,154 @@
+package nnut
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type TestAccount struct {
+	ID    string `nnut:"key"`
+	Email string `nnut:"index:Email,unique"`
+	Name  string `nnut:"index:Name"`
+}
+
+func TestUniqueIndex(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestAccount](db, "accounts")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	err = store.Put(context.Background(), TestAccount{ID: "a", Email: "alice@example.com", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	// Both buffered and flushed values are enforced
+	for _, flush := range []bool{false, true} {
+		if flush {
+			db.Flush()
+		}
+		err = store.Put(context.Background(), TestAccount{ID: "b", Email: "alice@example.com"})
+		uniqueErr, ok := err.(UniqueConstraintError)
+		if !ok {
+			t.Fatalf("Expected UniqueConstraintError, got %v", err)
+		}
+		if uniqueErr.Index != "Email" || uniqueErr.Key != "a" {
+			t.Fatalf("Expected conflict on Email with a, got %+v", uniqueErr)
+		}
+	}
+
+	// Writing the same value again for the same record is allowed
+	err = store.Put(context.Background(), TestAccount{ID: "a", Email: "alice@example.com", Name: "Alicia"})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	// Records in one batch may not share a value, but may swap values
+	err = store.PutBatch(context.Background(), []TestAccount{
+		{ID: "b", Email: "bob@example.com"},
+		{ID: "c", Email: "bob@example.com"},
+	})
+	if _, ok := err.(UniqueConstraintError); !ok {
+		t.Fatalf("Expected UniqueConstraintError, got %v", err)
+	}
+	err = store.Put(context.Background(), TestAccount{ID: "b", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	err = store.PutBatch(context.Background(), []TestAccount{
+		{ID: "a", Email: "bob@example.com"},
+		{ID: "b", Email: "alice@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to swap values: %v", err)
+	}
+
+	account, err := store.GetByUnique(context.Background(), "Email", "alice@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get by unique: %v", err)
+	}
+	if account.ID != "b" {
+		t.Fatalf("Expected b, got %s", account.ID)
+	}
+	_, err = store.GetByUnique(context.Background(), "Email", "nobody@example.com")
+	if _, ok := err.(KeyNotFoundError); !ok {
+		t.Fatalf("Expected KeyNotFoundError, got %v", err)
+	}
+	_, err = store.GetByUnique(context.Background(), "Name", "Alicia")
+	if _, ok := err.(InvalidQueryError); !ok {
+		t.Fatalf("Expected InvalidQueryError, got %v", err)
+	}
+
+	// Updates are checked as well
+	_, err = store.UpdateQuery(context.Background(), &Query{Conditions: []Condition{{Field: "Email", Value: "bob@example.com"}}}, func(account *TestAccount) error {
+		account.Email = "alice@example.com"
+		return nil
+	})
+	if _, ok := err.(UniqueConstraintError); !ok {
+		t.Fatalf("Expected UniqueConstraintError, got %v", err)
+	}
+
+	// Deleting a record frees its value
+	err = store.Delete(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	err = store.Put(context.Background(), TestAccount{ID: "c", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to put after delete: %v", err)
+	}
+}
+
+func TestUniqueIndexConcurrent(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestAccount](db, "accounts")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Only one of the writers racing for the same value succeeds
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.Put(context.Background(), TestAccount{ID: fmt.Sprint(i), Email: "shared@example.com"})
+			if err == nil {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+			} else if _, ok := err.(UniqueConstraintError); !ok {
+				t.Errorf("Expected UniqueConstraintError, got %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Fatalf("Expected 1 successful write, got %d", succeeded)
+	}
+}
@@ -0,0 +1,120 @@
+package nnut
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// UpdateQuery applies the mutator to each record matching the query and returns the count of updated records.
+// All changes are written as a single batch, so when the mutator fails for any record none are updated.
+// The batch is only written when none of the records changed since they were read, otherwise the query
+// starts over, so the mutator can be called more than once for a record and should only change the record.
+// Mutators run outside of any read of the database and may read from it, but must not write the records they
+// update. When the records keep changing a ConflictError is returned.
+func (s *Store[T]) UpdateQuery(ctx context.Context, query *Query, mutator func(*T) error) (int, error) {
+	if err := s.validateQuery(query); err != nil {
+		return 0, err
+	}
+
+	var updated int
+	err := retryConflicts(func() error {
+		reads, err := s.readForUpdate(ctx, query)
+		if err != nil {
+			return err
+		}
+		operations, err := s.mutateForUpdate(ctx, reads, mutator)
+		if err != nil {
+			return err
+		}
+
+		// The records must still be as the mutator saw them when the batch is written
+		unchanged := s.checkUnchanged(reads)
+		unique := s.checkUnique(operations)
+		check := func(snapshot *Snapshot) error {
+			if err := unchanged(snapshot); err != nil {
+				return err
+			}
+			if unique != nil {
+				return unique(snapshot)
+			}
+			return nil
+		}
+		updated = len(operations)
+		return s.database.writeCheckedOperations(ctx, operations, check)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// readForUpdate returns copies of the records matching the query against the stored and pending writes
+func (s *Store[T]) readForUpdate(ctx context.Context, query *Query) ([]recordRead, error) {
+	var reads []recordRead
+	err := s.database.view(ctx, s.bucket, nil, func(snapshot *Snapshot) error {
+		view := s.In(snapshot)
+		keysToUpdate, err := view.getQueryKeys(query)
+		if err != nil {
+			return err
+		}
+		reads = view.readRecords(keysToUpdate)
+		return nil
+	})
+	return reads, err
+}
+
+// mutateForUpdate applies the mutator to the records read for an update and returns the operations writing them
+func (s *Store[T]) mutateForUpdate(ctx context.Context, reads []recordRead, mutator func(*T) error) ([]operation, error) {
+	var operations []operation
+	decoder := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(decoder)
+	for _, read := range reads {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		var item T
+		decoder.Reset(bytes.NewReader(read.data))
+		err := decoder.Decode(&item)
+		if err != nil {
+			return nil, WrappedError{Operation: "decode", Bucket: string(s.bucket), Key: s.formatKey(read.key), Err: err}
+		}
+		oldIndexValues := s.extractIndexValues(item)
+
+		err = mutator(&item)
+		if err != nil {
+			return nil, WrappedError{Operation: "update", Bucket: string(s.bucket), Key: s.formatKey(read.key), Err: err}
+		}
+		// Moving a record to another key would leave the original behind
+		newKey, err := s.keyOf(item)
+		if err != nil {
+			return nil, WrappedError{Operation: "update", Bucket: string(s.bucket), Key: s.formatKey(read.key), Err: err}
+		}
+		if newKey != read.key {
+			return nil, WrappedError{Operation: "update", Bucket: string(s.bucket), Key: s.formatKey(read.key), Err: InvalidKeyError{Key: s.formatKey(newKey)}}
+		}
+
+		// Prepare index maintenance operations
+		indexOperations := s.indexOperations(oldIndexValues, s.extractIndexValues(item))
+
+		newData, err := msgpack.Marshal(item)
+		if err != nil {
+			return nil, WrappedError{Operation: "marshal", Bucket: string(s.bucket), Key: s.formatKey(read.key), Err: err}
+		}
+
+		// Soft-deleted records matched through IncludeDeleted stay deleted
+		operations = append(operations, operation{
+			Bucket:          s.bucket,
+			Key:             read.key,
+			Value:           newData,
+			IsPut:           true,
+			IndexOperations: indexOperations,
+			DeletedAt:       read.deletedAt,
+		})
+	}
+	return operations, nil
+}
@@ -0,0 +1,272 @@
+package nnut
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateQuery(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUsers := []TestUser{
+		{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30},
+		{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25},
+		{UUID: "3", Name: "Charlie", Email: "charlie@example.com", Age: 35},
+	}
+	err = store.PutBatch(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+	db.Flush()
+
+	updatedCount, err := store.UpdateQuery(context.Background(), &Query{
+		Conditions: []Condition{
+			{Field: "Age", Value: 28, Operator: GreaterThan},
+		},
+	}, func(user *TestUser) error {
+		user.Name = "Archived"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update query: %v", err)
+	}
+	if updatedCount != 2 {
+		t.Fatalf("Expected 2 updates, got %d", updatedCount)
+	}
+
+	// Changed index values are moved to the new value
+	results, err := store.GetQuery(context.Background(), &Query{Index: "name"})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[0].Name != "Archived" || results[1].Name != "Archived" || results[2].Name != "Bob" {
+		t.Fatalf("Expected index order Archived, Archived, Bob, got %v", results)
+	}
+	db.Flush()
+	user, err := store.Get(context.Background(), "3")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if user.Name != "Archived" || user.Age != 35 {
+		t.Fatalf("Unexpected record after update: %+v", user)
+	}
+}
+
+func TestUpdateQueryLimitOffset(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUsers := []TestUser{
+		{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30},
+		{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25},
+		{UUID: "3", Name: "Charlie", Email: "charlie@example.com", Age: 35},
+	}
+	err = store.PutBatch(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	updatedCount, err := store.UpdateQuery(context.Background(), &Query{Offset: 1, Limit: 1}, func(user *TestUser) error {
+		user.Age++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update query: %v", err)
+	}
+	if updatedCount != 1 {
+		t.Fatalf("Expected 1 update, got %d", updatedCount)
+	}
+	user, err := store.Get(context.Background(), "2")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if user.Age != 26 {
+		t.Fatalf("Expected age 26, got %d", user.Age)
+	}
+}
+
+func TestUpdateQueryErrors(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUsers := []TestUser{
+		{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30},
+		{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25},
+	}
+	err = store.PutBatch(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// A failing mutator leaves every record untouched
+	errFailed := errors.New("failed")
+	updatedCount, err := store.UpdateQuery(context.Background(), &Query{}, func(user *TestUser) error {
+		if user.UUID == "2" {
+			return errFailed
+		}
+		user.Name = "Changed"
+		return nil
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("Expected mutator error, got %v", err)
+	}
+	if updatedCount != 0 {
+		t.Fatalf("Expected 0 updates, got %d", updatedCount)
+	}
+	user, err := store.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if user.Name != "Alice" {
+		t.Fatalf("Expected Alice, got %s", user.Name)
+	}
+
+	// Changing the primary key is rejected
+	_, err = store.UpdateQuery(context.Background(), &Query{}, func(user *TestUser) error {
+		user.UUID = "other"
+		return nil
+	})
+	var invalidKeyError InvalidKeyError
+	if !errors.As(err, &invalidKeyError) {
+		t.Fatalf("Expected InvalidKeyError, got %v", err)
+	}
+
+	// A cancelled context stops the update
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = store.UpdateQuery(ctx, &Query{}, func(user *TestUser) error {
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestUpdateQueryConcurrentWrite(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), t.Name()+".db")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + ".wal")
+
+	store, err := NewStore[TestUser](db, "users")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	testUsers := []TestUser{
+		{UUID: "1", Name: "Alice", Email: "alice@example.com", Age: 30},
+		{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: 25},
+	}
+	err = store.PutBatch(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("Failed to put batch: %v", err)
+	}
+
+	// A write to a matching record while the mutators run makes the update start over from it
+	calls := 0
+	updatedCount, err := store.UpdateQuery(context.Background(), &Query{}, func(user *TestUser) error {
+		calls++
+		if calls == 1 {
+			// Mutators may read from the database, and another writer changes the record meanwhile
+			if _, err := store.Get(context.Background(), "2"); err != nil {
+				return err
+			}
+			done := make(chan error)
+			go func() {
+				done <- store.Put(context.Background(), TestUser{UUID: "1", Name: "Alice", Email: "alice@example.org", Age: 31})
+			}()
+			if err := <-done; err != nil {
+				return err
+			}
+		}
+		user.Age++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update query: %v", err)
+	}
+	if updatedCount != 2 || calls != 4 {
+		t.Fatalf("Expected 2 updates after 4 mutator calls, got %d after %d", updatedCount, calls)
+	}
+	user, err := store.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if user.Email != "alice@example.org" || user.Age != 32 {
+		t.Fatalf("Expected the concurrent write to be kept and updated, got %+v", user)
+	}
+
+	// Records that keep changing fail the update without writing it
+	age := 0
+	_, err = store.UpdateQuery(context.Background(), &Query{}, func(user *TestUser) error {
+		age++
+		done := make(chan error)
+		go func() {
+			done <- store.Put(context.Background(), TestUser{UUID: "2", Name: "Bob", Email: "bob@example.com", Age: age})
+		}()
+		if err := <-done; err != nil {
+			return err
+		}
+		user.Name = "Changed"
+		return nil
+	})
+	var conflict ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected ConflictError, got %v", err)
+	}
+	user, err = store.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if user.Name != "Alice" {
+		t.Fatalf("Expected no record to be updated, got %+v", user)
+	}
+}
@@ -0,0 +1,12 @@
+package nnut
+
+// StoreView is a read-only view of a store pinned to a snapshot
+type StoreView[T any] struct {
+	store    *Store[T]
+	snapshot *Snapshot
+}
+
+// In returns a read-only view of the store as of the snapshot
+func (s *Store[T]) In(snapshot *Snapshot) *StoreView[T] {
+	return &StoreView[T]{store: s, snapshot: snapshot}
+}